@@ -0,0 +1,447 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+	"github.com/Srinu0342/mocknest/server/generator"
+)
+
+// Register mounts the /__admin endpoints on mux.
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /__admin/mocks", listMocks)
+	mux.HandleFunc("POST /__admin/mocks", createMock)
+	mux.HandleFunc("GET /__admin/mocks/{id}", getMock)
+	mux.HandleFunc("PUT /__admin/mocks/{id}", updateMock)
+	mux.HandleFunc("DELETE /__admin/mocks/{id}", deleteMock)
+	mux.HandleFunc("POST /__admin/mocks/{id}/responses/reset", resetMockResponses)
+	mux.HandleFunc("POST /__admin/responses/reset", resetResponses)
+
+	mux.HandleFunc("GET /__admin/reload", reloadStatus)
+	mux.HandleFunc("POST /__admin/reload", reload)
+
+	mux.HandleFunc("GET /__admin/scenarios", listScenarios)
+	mux.HandleFunc("POST /__admin/scenarios/reset", resetScenarios)
+	mux.HandleFunc("POST /__admin/scenarios/{name}/reset", resetScenario)
+	mux.HandleFunc("PUT /__admin/scenarios/{name}/state", setScenarioState)
+
+	mux.HandleFunc("GET /__admin/chaos", getChaos)
+	mux.HandleFunc("PUT /__admin/chaos", setChaos)
+	mux.HandleFunc("DELETE /__admin/chaos", disableChaos)
+
+	mux.HandleFunc("GET /__admin/proxy", getProxy)
+	mux.HandleFunc("PUT /__admin/proxy", setProxy)
+	mux.HandleFunc("DELETE /__admin/proxy", disableProxy)
+
+	mux.HandleFunc("GET /__admin/recording", getRecording)
+	mux.HandleFunc("PUT /__admin/recording", setRecording)
+
+	mux.HandleFunc("GET /__admin/history", listHistory)
+	mux.HandleFunc("DELETE /__admin/history", func(w http.ResponseWriter, r *http.Request) {
+		appdata.ResetCallHistory()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /__admin/history/stats", historyStats)
+	mux.HandleFunc("GET /__admin/history/config", getHistoryConfig)
+	mux.HandleFunc("PUT /__admin/history/config", setHistoryConfig)
+	mux.HandleFunc("POST /__admin/verify", verify)
+	mux.HandleFunc("GET /__admin/near-misses", nearMisses)
+	mux.HandleFunc("GET /__admin/unmatched", listUnmatched)
+	mux.HandleFunc("POST /__admin/unmatched/stub", stubUnmatched)
+}
+
+func listMocks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.GetAllMappings())
+}
+
+func getMock(w http.ResponseWriter, r *http.Request) {
+	m, ok := appdata.GetMapping(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "mapping not found", r.PathValue("id"))
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func createMock(w http.ResponseWriter, r *http.Request) {
+	var m appdata.Mapping
+	if !decodeBody(w, r, &m) {
+		return
+	}
+	created, err := appdata.CreateMapping(m)
+	if err != nil {
+		writeMappingError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func updateMock(w http.ResponseWriter, r *http.Request) {
+	var m appdata.Mapping
+	if !decodeBody(w, r, &m) {
+		return
+	}
+	updated, err := appdata.UpdateMapping(r.PathValue("id"), m)
+	if err != nil {
+		writeMappingError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func deleteMock(w http.ResponseWriter, r *http.Request) {
+	if err := appdata.DeleteMapping(r.PathValue("id")); err != nil {
+		writeMappingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func resetMockResponses(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := appdata.GetMapping(id); !ok {
+		writeError(w, http.StatusNotFound, "mapping not found", id)
+		return
+	}
+	appdata.Global.ResetMappingResponses(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func resetResponses(w http.ResponseWriter, r *http.Request) {
+	appdata.Global.ResetResponses()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func reloadStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, generator.Status())
+}
+
+func reload(w http.ResponseWriter, r *http.Request) {
+	if err := generator.Reload(); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, generator.Status())
+		return
+	}
+	writeJSON(w, http.StatusOK, generator.Status())
+}
+
+func listScenarios(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.Global.Scenarios())
+}
+
+func resetScenarios(w http.ResponseWriter, r *http.Request) {
+	appdata.Global.ResetScenarios()
+	writeJSON(w, http.StatusOK, appdata.Global.Scenarios())
+}
+
+func resetScenario(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !appdata.Global.HasScenario(name) {
+		writeError(w, http.StatusNotFound, "scenario not found", name)
+		return
+	}
+	appdata.Global.ResetScenario(name)
+	writeJSON(w, http.StatusOK, appdata.Global.Scenarios())
+}
+
+func setScenarioState(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !appdata.Global.HasScenario(name) {
+		writeError(w, http.StatusNotFound, "scenario not found", name)
+		return
+	}
+	var req struct {
+		State string `json:"state"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.State == "" {
+		writeError(w, http.StatusBadRequest, "invalid scenario state", "state is required")
+		return
+	}
+	appdata.Global.SetScenarioState(name, req.State)
+	writeJSON(w, http.StatusOK, appdata.Global.Scenarios())
+}
+
+func getChaos(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.GetChaosProfile())
+}
+
+func setChaos(w http.ResponseWriter, r *http.Request) {
+	var p appdata.ChaosProfile
+	if !decodeBody(w, r, &p) {
+		return
+	}
+	if err := appdata.SetChaosProfile(p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid chaos profile", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, appdata.GetChaosProfile())
+}
+
+func disableChaos(w http.ResponseWriter, r *http.Request) {
+	_ = appdata.SetChaosProfile(appdata.ChaosProfile{})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getProxy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.GetProxyConfig())
+}
+
+func setProxy(w http.ResponseWriter, r *http.Request) {
+	var c appdata.ProxyConfig
+	if !decodeBody(w, r, &c) {
+		return
+	}
+	if err := appdata.SetProxyConfig(c); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid proxy config", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, appdata.GetProxyConfig())
+}
+
+func disableProxy(w http.ResponseWriter, r *http.Request) {
+	_ = appdata.SetProxyConfig(appdata.ProxyConfig{})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getRecording(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, generator.GetRecordConfig())
+}
+
+func setRecording(w http.ResponseWriter, r *http.Request) {
+	var c generator.RecordConfig
+	if !decodeBody(w, r, &c) {
+		return
+	}
+	if err := generator.SetRecordConfig(c); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record config", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, generator.GetRecordConfig())
+}
+
+// listHistory returns the calls selected by the query parameters (see
+// historyQuery). X-Total-Count is the number of calls before paging.
+func listHistory(w http.ResponseWriter, r *http.Request) {
+	q, err := historyQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid history query", err.Error())
+		return
+	}
+	calls, total, err := appdata.QueryCallHistory(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid history query", err.Error())
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, http.StatusOK, calls)
+}
+
+// historyQuery reads method, urlPrefix, urlRegex, mappingId, status ("404"
+// or "400-499"), unmatched, tag (repeatable), since and until (RFC 3339),
+// body.<path> predicates, sort, offset and limit.
+func historyQuery(params url.Values) (appdata.HistoryQuery, error) {
+	q := appdata.HistoryQuery{
+		Method:    params.Get("method"),
+		URLPrefix: params.Get("urlPrefix"),
+		URLRegex:  params.Get("urlRegex"),
+		MappingID: params.Get("mappingId"),
+		Tags:      params["tag"],
+		Sort:      params.Get("sort"),
+	}
+
+	ints := map[string]*int{"offset": &q.Offset, "limit": &q.Limit}
+	for name, dst := range ints {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return q, fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+
+	times := map[string]*time.Time{"since": &q.Since, "until": &q.Until}
+	for name, dst := range times {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return q, fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = t
+		}
+	}
+
+	if v := params.Get("status"); v != "" {
+		lo, hi, isRange := strings.Cut(v, "-")
+		if !isRange {
+			hi = lo
+		}
+		minStatus, err := strconv.Atoi(lo)
+		if err != nil {
+			return q, fmt.Errorf("status: %w", err)
+		}
+		maxStatus, err := strconv.Atoi(hi)
+		if err != nil {
+			return q, fmt.Errorf("status: %w", err)
+		}
+		q.StatusMin, q.StatusMax = &minStatus, &maxStatus
+	}
+
+	if v := params.Get("unmatched"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("unmatched: %w", err)
+		}
+		q.Unmatched = b
+	}
+
+	// body.customer.id=c-1 matches the field against "c-1"; values that
+	// parse as JSON are used as such, so body.total=42 expects a number and
+	// body.total={"greaterThan":40} applies an operator.
+	for name, vs := range params {
+		path, ok := strings.CutPrefix(name, "body.")
+		if !ok || path == "" {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal([]byte(vs[0]), &v); err != nil {
+			v = vs[0]
+		}
+		if q.Body == nil {
+			q.Body = make(map[string]any)
+		}
+		q.Body[path] = v
+	}
+	return q, nil
+}
+
+func historyStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.GetHistoryStats())
+}
+
+func getHistoryConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.GetHistoryConfig())
+}
+
+// setHistoryConfig merges the body over the current limits, so a partial
+// update such as {"maxEntries": 500} keeps the redacted headers.
+func setHistoryConfig(w http.ResponseWriter, r *http.Request) {
+	c := appdata.GetHistoryConfig()
+	if !decodeBody(w, r, &c) {
+		return
+	}
+	if err := appdata.SetHistoryConfig(c); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid history config", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, appdata.GetHistoryConfig())
+}
+
+func verify(w http.ResponseWriter, r *http.Request) {
+	var v appdata.Verification
+	if !decodeBody(w, r, &v) {
+		return
+	}
+	res, err := appdata.Verify(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid verification", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func nearMisses(w http.ResponseWriter, r *http.Request) {
+	limit := 3
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit", v)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, appdata.UnmatchedNearMisses(limit))
+}
+
+func listUnmatched(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, appdata.UnmatchedCalls())
+}
+
+// stubUnmatched drafts a mapping for a group of unmatched calls and, with
+// "install": true, adds it right away.
+func stubUnmatched(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method        string `json:"method"`
+		PathSignature string `json:"pathSignature"`
+		Install       bool   `json:"install"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	draft, err := appdata.DraftMapping(req.Method, req.PathSignature)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "unmatched calls not found", err.Error())
+		return
+	}
+	if !req.Install {
+		writeJSON(w, http.StatusOK, draft)
+		return
+	}
+	created, err := appdata.CreateMapping(draft)
+	if err != nil {
+		writeMappingError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func writeMappingError(w http.ResponseWriter, err error) {
+	var verr *appdata.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		writeError(w, http.StatusBadRequest, "invalid mapping", err.Error())
+	case errors.Is(err, appdata.ErrMappingNotFound):
+		writeError(w, http.StatusNotFound, "mapping not found", err.Error())
+	case errors.Is(err, appdata.ErrMappingExists):
+		writeError(w, http.StatusConflict, "mapping already exists", err.Error())
+	case errors.Is(err, appdata.ErrMappingFromFile):
+		writeError(w, http.StatusConflict, "mapping is defined in the mocks directory", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "mapping update failed", err.Error())
+	}
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, msg, details string) {
+	writeJSON(w, status, map[string]any{
+		"error":   msg,
+		"details": details,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "failed to encode json", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(b, '\n'))
+}
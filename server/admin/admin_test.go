@@ -0,0 +1,420 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+	"github.com/Srinu0342/mocknest/server/generator"
+)
+
+// resetState clears the index and call history for the test.
+func resetState(t *testing.T) {
+	t.Helper()
+	appdata.Global.Reset()
+	appdata.ResetCallHistory()
+	t.Cleanup(func() {
+		appdata.Global.Reset()
+		appdata.ResetCallHistory()
+	})
+}
+
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	Register(mux)
+	return mux
+}
+
+// do sends one request to mux and decodes a JSON response into out (if non-nil).
+func do(t *testing.T, mux *http.ServeMux, method, target, body string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, target, rec.Body, err)
+		}
+	}
+	return rec
+}
+
+// Test that a partial history config update keeps the fields it leaves out,
+// redaction in particular.
+func TestSetHistoryConfigMerges(t *testing.T) {
+	t.Cleanup(func() { _ = appdata.SetHistoryConfig(appdata.DefaultHistoryConfig) })
+	mux := newMux()
+
+	var got appdata.HistoryConfig
+	if rec := do(t, mux, "PUT", "/__admin/history/config", `{"maxEntries": 500}`, &got); rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	want := appdata.DefaultHistoryConfig
+	want.MaxEntries = 500
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(appdata.GetHistoryConfig(), want) {
+		t.Fatalf("config = %+v, want %+v", got, want)
+	}
+
+	do(t, mux, "PUT", "/__admin/history/config", `{"redactHeaders": []}`, &got)
+	if len(got.RedactHeaders) != 0 || got.MaxEntries != 500 {
+		t.Fatalf("config = %+v, want redaction off and maxEntries kept", got)
+	}
+
+	if rec := do(t, mux, "PUT", "/__admin/history/config", `{"ttlMs": -1}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid config status = %d, want 400", rec.Code)
+	}
+}
+
+// apiError is the body writeError sends.
+type apiError struct {
+	Error   string `json:"error"`
+	Details string `json:"details"`
+}
+
+// Test the mapping CRUD routes, including validation and unknown ids.
+func TestMocksCRUD(t *testing.T) {
+	resetState(t)
+	mux := newMux()
+	orders := `{"id":"orders","request":{"method":"GET","urlPattern":"/orders"},"response":{"status":200}}`
+
+	var created appdata.Mapping
+	if rec := do(t, mux, "POST", "/__admin/mocks", orders, &created); rec.Code != http.StatusCreated || created.ID != "orders" {
+		t.Fatalf("POST = %d %+v, want 201 orders", rec.Code, created)
+	}
+	var e apiError
+	if rec := do(t, mux, "POST", "/__admin/mocks", orders, &e); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate POST = %d %+v, want 409", rec.Code, e)
+	}
+	if rec := do(t, mux, "POST", "/__admin/mocks", `{"request":{"method":"GET","urlPattern":"(","urlMatch":"regex"}}`, &e); rec.Code != http.StatusBadRequest ||
+		e.Error != "invalid mapping" || !strings.Contains(e.Details, "invalid urlPattern regex") {
+		t.Fatalf("invalid POST = %d %+v, want 400 with details", rec.Code, e)
+	}
+	if rec := do(t, mux, "POST", "/__admin/mocks", `{`, &e); rec.Code != http.StatusBadRequest || e.Error != "invalid json" {
+		t.Fatalf("malformed POST = %d %+v, want 400 invalid json", rec.Code, e)
+	}
+	var generated appdata.Mapping
+	if rec := do(t, mux, "POST", "/__admin/mocks", `{"request":{"method":"GET","urlPattern":"/gen"}}`, &generated); rec.Code != http.StatusCreated || generated.ID == "" {
+		t.Fatalf("POST without id = %d %+v, want a generated id", rec.Code, generated)
+	}
+
+	var list []appdata.Mapping
+	if do(t, mux, "GET", "/__admin/mocks", "", &list); len(list) != 2 {
+		t.Fatalf("GET list = %+v, want 2 mappings", list)
+	}
+	var got appdata.Mapping
+	if rec := do(t, mux, "GET", "/__admin/mocks/orders", "", &got); rec.Code != http.StatusOK || got.Request.URLPattern != "/orders" {
+		t.Fatalf("GET orders = %d %+v", rec.Code, got)
+	}
+	if rec := do(t, mux, "GET", "/__admin/mocks/nope", "", &e); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET unknown = %d, want 404", rec.Code)
+	}
+
+	updated := `{"request":{"method":"GET","urlPattern":"/orders/v2"},"response":{"status":202}}`
+	if rec := do(t, mux, "PUT", "/__admin/mocks/orders", updated, &got); rec.Code != http.StatusOK || got.ID != "orders" || got.Response.Status != 202 {
+		t.Fatalf("PUT = %d %+v", rec.Code, got)
+	}
+	if _, ok := appdata.Global.Match(appdata.IncomingRequest{Method: "GET", URL: "/orders/v2"}); !ok {
+		t.Fatal("PUT did not update the index")
+	}
+	if rec := do(t, mux, "PUT", "/__admin/mocks/nope", updated, &e); rec.Code != http.StatusNotFound {
+		t.Fatalf("PUT unknown = %d, want 404", rec.Code)
+	}
+	if rec := do(t, mux, "PUT", "/__admin/mocks/orders", `{"id":"other"}`, &e); rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT mismatched id = %d, want 400", rec.Code)
+	}
+
+	if rec := do(t, mux, "DELETE", "/__admin/mocks/orders", "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d, want 204", rec.Code)
+	}
+	if rec := do(t, mux, "DELETE", "/__admin/mocks/orders", "", &e); rec.Code != http.StatusNotFound {
+		t.Fatalf("DELETE again = %d, want 404", rec.Code)
+	}
+}
+
+// Test the response sequence reset routes.
+func TestResetResponses(t *testing.T) {
+	resetState(t)
+	mux := newMux()
+	seq := `{"id":"seq","request":{"method":"GET","urlPattern":"/seq"},"responses":[{"status":200},{"status":201}]}`
+	do(t, mux, "POST", "/__admin/mocks", seq, nil)
+
+	next := func() int {
+		res, _ := appdata.Global.Match(appdata.IncomingRequest{Method: "GET", URL: "/seq"})
+		r, _ := res.Render(appdata.IncomingRequest{Method: "GET", URL: "/seq"})
+		return r.Status
+	}
+	next()
+	if rec := do(t, mux, "POST", "/__admin/mocks/seq/responses/reset", "", nil); rec.Code != http.StatusNoContent || next() != 200 {
+		t.Fatalf("reset mapping = %d, want 204 and the first response again", rec.Code)
+	}
+	if rec := do(t, mux, "POST", "/__admin/mocks/nope/responses/reset", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("reset unknown = %d, want 404", rec.Code)
+	}
+	if rec := do(t, mux, "POST", "/__admin/responses/reset", "", nil); rec.Code != http.StatusNoContent || next() != 200 {
+		t.Fatalf("reset all = %d, want 204 and the first response again", rec.Code)
+	}
+}
+
+// Test the reload routes against a temporary mocks directory.
+func TestReload(t *testing.T) {
+	resetState(t)
+	t.Chdir(t.TempDir())
+	mux := newMux()
+
+	var s generator.ReloadStatus
+	if rec := do(t, mux, "POST", "/__admin/reload", "", &s); rec.Code != http.StatusUnprocessableEntity || s.Error == "" {
+		t.Fatalf("reload without mocks dir = %d %+v, want 422 with error", rec.Code, s)
+	}
+	if err := os.Mkdir(generator.MocksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(generator.MocksDir, "a.json"), []byte(`{"id":"a","request":{"method":"GET","urlPattern":"/a"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s = generator.ReloadStatus{}
+	if rec := do(t, mux, "POST", "/__admin/reload", "", &s); rec.Code != http.StatusOK || s.Mappings != 1 || s.Error != "" {
+		t.Fatalf("reload = %d %+v, want 200 with 1 mapping", rec.Code, s)
+	}
+	if rec := do(t, mux, "GET", "/__admin/reload", "", &s); rec.Code != http.StatusOK || s.Mappings != 1 {
+		t.Fatalf("reload status = %d %+v", rec.Code, s)
+	}
+
+	var e apiError
+	if rec := do(t, mux, "PUT", "/__admin/mocks/a", `{"request":{"method":"GET","urlPattern":"/b"}}`, &e); rec.Code != http.StatusConflict {
+		t.Fatalf("PUT file mapping = %d %+v, want 409", rec.Code, e)
+	}
+	if rec := do(t, mux, "DELETE", "/__admin/mocks/a", "", &e); rec.Code != http.StatusConflict {
+		t.Fatalf("DELETE file mapping = %d %+v, want 409", rec.Code, e)
+	}
+	do(t, mux, "POST", "/__admin/reload", "", nil)
+	if _, ok := appdata.Global.Match(appdata.IncomingRequest{Method: "GET", URL: "/a"}); !ok {
+		t.Fatal("file mapping gone after reload")
+	}
+}
+
+// Test the scenario routes.
+func TestScenarios(t *testing.T) {
+	resetState(t)
+	mux := newMux()
+	do(t, mux, "POST", "/__admin/mocks", `{"id":"s","scenarioName":"cart","requiredScenarioState":"Started","newScenarioState":"FULL","request":{"method":"GET","urlPattern":"/cart"}}`, nil)
+
+	state := func(list []appdata.ScenarioInfo) string {
+		if len(list) != 1 {
+			t.Fatalf("scenarios = %+v, want one", list)
+		}
+		return list[0].State
+	}
+	var list []appdata.ScenarioInfo
+	if do(t, mux, "GET", "/__admin/scenarios", "", &list); state(list) != "Started" {
+		t.Fatalf("initial state = %q", state(list))
+	}
+	if rec := do(t, mux, "PUT", "/__admin/scenarios/cart/state", `{"state":"FULL"}`, &list); rec.Code != http.StatusOK || state(list) != "FULL" {
+		t.Fatalf("set state = %d %+v", rec.Code, list)
+	}
+	if rec := do(t, mux, "PUT", "/__admin/scenarios/cart/state", `{}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty state = %d, want 400", rec.Code)
+	}
+	if rec := do(t, mux, "PUT", "/__admin/scenarios/nope/state", `{"state":"X"}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown scenario = %d, want 404", rec.Code)
+	}
+	if do(t, mux, "POST", "/__admin/scenarios/cart/reset", "", &list); state(list) != "Started" {
+		t.Fatalf("reset one: state %q", state(list))
+	}
+	if rec := do(t, mux, "POST", "/__admin/scenarios/nope/reset", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("reset unknown = %d, want 404", rec.Code)
+	}
+	do(t, mux, "PUT", "/__admin/scenarios/cart/state", `{"state":"FULL"}`, nil)
+	if do(t, mux, "POST", "/__admin/scenarios/reset", "", &list); state(list) != "Started" {
+		t.Fatalf("reset all: state %q", state(list))
+	}
+}
+
+// Test the chaos, proxy and recording configuration routes.
+func TestRuntimeConfig(t *testing.T) {
+	t.Cleanup(func() {
+		_ = appdata.SetChaosProfile(appdata.ChaosProfile{})
+		_ = appdata.SetProxyConfig(appdata.ProxyConfig{})
+		_ = generator.SetRecordConfig(generator.RecordConfig{})
+	})
+	mux := newMux()
+
+	var chaos appdata.ChaosProfile
+	if rec := do(t, mux, "PUT", "/__admin/chaos", `{"enabled":true,"rules":[{"errorRate":0.5}]}`, &chaos); rec.Code != http.StatusOK || !chaos.Enabled {
+		t.Fatalf("PUT chaos = %d %+v", rec.Code, chaos)
+	}
+	if rec := do(t, mux, "PUT", "/__admin/chaos", `{"rules":[{"errorRate":2}]}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid chaos = %d, want 400", rec.Code)
+	}
+	if do(t, mux, "GET", "/__admin/chaos", "", &chaos); !chaos.Enabled {
+		t.Fatal("GET chaos lost the profile")
+	}
+	if rec := do(t, mux, "DELETE", "/__admin/chaos", "", nil); rec.Code != http.StatusNoContent || appdata.GetChaosProfile().Enabled {
+		t.Fatalf("DELETE chaos = %d", rec.Code)
+	}
+
+	var proxy appdata.ProxyConfig
+	if rec := do(t, mux, "PUT", "/__admin/proxy", `{"baseUrl":"http://upstream:8080"}`, &proxy); rec.Code != http.StatusOK || proxy.BaseURL != "http://upstream:8080" {
+		t.Fatalf("PUT proxy = %d %+v", rec.Code, proxy)
+	}
+	if rec := do(t, mux, "PUT", "/__admin/proxy", `{"baseUrl":"ftp://x"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid proxy = %d, want 400", rec.Code)
+	}
+	if do(t, mux, "GET", "/__admin/proxy", "", &proxy); proxy.BaseURL != "http://upstream:8080" {
+		t.Fatalf("GET proxy = %+v", proxy)
+	}
+	if rec := do(t, mux, "DELETE", "/__admin/proxy", "", nil); rec.Code != http.StatusNoContent || appdata.GetProxyConfig().BaseURL != "" {
+		t.Fatalf("DELETE proxy = %d", rec.Code)
+	}
+
+	var recording generator.RecordConfig
+	if rec := do(t, mux, "PUT", "/__admin/recording", `{"enabled":true,"bodyFields":["a.b"]}`, &recording); rec.Code != http.StatusOK || !recording.Enabled {
+		t.Fatalf("PUT recording = %d %+v", rec.Code, recording)
+	}
+	if rec := do(t, mux, "PUT", "/__admin/recording", `{"bodyFields":["$["]}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid recording = %d, want 400", rec.Code)
+	}
+	if do(t, mux, "GET", "/__admin/recording", "", &recording); !recording.Enabled {
+		t.Fatal("GET recording lost the config")
+	}
+}
+
+// Test the history listing, query parsing, stats and reset routes.
+func TestHistory(t *testing.T) {
+	resetState(t)
+	t.Cleanup(func() { _ = appdata.SetHistoryConfig(appdata.DefaultHistoryConfig) })
+	mux := newMux()
+	do(t, mux, "POST", "/__admin/mocks", `{"id":"pay","request":{"method":"POST","urlPattern":"/pay"},"metadata":{"tags":["payments"]}}`, nil)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range []appdata.CallRecord{
+		{Method: "POST", URL: "/pay", MappingID: "pay", Status: 201, RequestBody: map[string]any{"amount": 50.0}},
+		{Method: "POST", URL: "/pay", MappingID: "pay", Status: 503, RequestBody: map[string]any{"amount": 5.0}},
+		{Method: "GET", URL: "/missing", Status: 404},
+		{Method: "GET", URL: "/slow", Status: 0},
+	} {
+		c.Time = start.Add(time.Duration(i) * time.Minute)
+		appdata.RecordCall(c)
+	}
+
+	tests := []struct {
+		query     string
+		wantURLs  string
+		wantTotal string
+	}{
+		{"", "/pay /pay /missing /slow", "4"},
+		{"?status=400-499", "/missing", "1"},
+		{"?status=503", "/pay", "1"},
+		{"?status=0", "/slow", "1"},
+		{"?method=post&body.amount=5", "/pay", "1"},
+		{"?body.amount=" + url.QueryEscape(`{"greaterThan":10}`), "/pay", "1"},
+		{"?tag=payments&tag=other", "/pay /pay", "2"},
+		{"?unmatched=true&urlPrefix=/mis", "/missing", "1"},
+		{"?urlRegex=" + url.QueryEscape("^/(pay|slow)$") + "&sort=-time&limit=2", "/slow /pay", "3"},
+		{"?mappingId=pay&offset=1", "/pay", "2"},
+		{"?since=2024-01-01T00:01:00Z&until=2024-01-01T00:03:00Z", "/pay /missing", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var calls []appdata.CallRecord
+			rec := do(t, mux, "GET", "/__admin/history"+tt.query, "", &calls)
+			var urls []string
+			for _, c := range calls {
+				urls = append(urls, c.URL)
+			}
+			if got := strings.Join(urls, " "); rec.Code != http.StatusOK || got != tt.wantURLs || rec.Header().Get("X-Total-Count") != tt.wantTotal {
+				t.Fatalf("= %d %q (total %s), want %q (total %s)", rec.Code, got, rec.Header().Get("X-Total-Count"), tt.wantURLs, tt.wantTotal)
+			}
+		})
+	}
+
+	for _, q := range []string{"?status=abc", "?status=400-", "?sort=size", "?limit=x", "?offset=-1", "?since=yesterday", "?unmatched=maybe", "?urlRegex=("} {
+		var e apiError
+		if rec := do(t, mux, "GET", "/__admin/history"+q, "", &e); rec.Code != http.StatusBadRequest || e.Error != "invalid history query" {
+			t.Errorf("%s = %d %+v, want 400", q, rec.Code, e)
+		}
+	}
+
+	if rec := do(t, mux, "POST", "/__admin/history", "", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST history = %d, want 405", rec.Code)
+	}
+
+	var stats appdata.HistoryStats
+	if do(t, mux, "GET", "/__admin/history/stats", "", &stats); stats.Entries != 4 {
+		t.Fatalf("stats = %+v, want 4 entries", stats)
+	}
+	var cfg appdata.HistoryConfig
+	if do(t, mux, "GET", "/__admin/history/config", "", &cfg); cfg.MaxEntries != appdata.DefaultHistoryConfig.MaxEntries {
+		t.Fatalf("config = %+v", cfg)
+	}
+	if rec := do(t, mux, "DELETE", "/__admin/history", "", nil); rec.Code != http.StatusNoContent || len(appdata.GetCallHistory()) != 0 {
+		t.Fatalf("DELETE history = %d", rec.Code)
+	}
+}
+
+// Test the verification route.
+func TestVerify(t *testing.T) {
+	resetState(t)
+	mux := newMux()
+	appdata.RecordCall(appdata.CallRecord{Method: "POST", URL: "/orders", Time: time.Now()})
+
+	var res appdata.VerificationResult
+	if rec := do(t, mux, "POST", "/__admin/verify", `{"request":{"urlPattern":"/orders"},"exactly":1}`, &res); rec.Code != http.StatusOK || !res.Passed {
+		t.Fatalf("verify = %d %+v", rec.Code, res)
+	}
+	if do(t, mux, "POST", "/__admin/verify", `{"request":{"method":"GET"}}`, &res); res.Passed || res.Count != 0 {
+		t.Fatalf("verify GET = %+v, want failed", res)
+	}
+	if rec := do(t, mux, "POST", "/__admin/verify", `{"exactly":1,"atLeast":1}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid verify = %d, want 400", rec.Code)
+	}
+}
+
+// Test the near-miss, unmatched journal and draft stub routes.
+func TestUnmatched(t *testing.T) {
+	resetState(t)
+	mux := newMux()
+	do(t, mux, "POST", "/__admin/mocks", `{"id":"user","request":{"method":"GET","urlPattern":"/users/1"}}`, nil)
+	do(t, mux, "POST", "/__admin/mocks", `{"id":"users","request":{"method":"GET","urlPattern":"/users"}}`, nil)
+	for _, u := range []string{"/users/42", "/users/43"} {
+		appdata.RecordCall(appdata.CallRecord{Method: "GET", URL: u, Status: 404, Time: time.Now()})
+	}
+
+	var misses []appdata.CallNearMisses
+	if rec := do(t, mux, "GET", "/__admin/near-misses?limit=1", "", &misses); rec.Code != http.StatusOK || len(misses) != 2 || len(misses[0].NearMisses) != 1 {
+		t.Fatalf("near-misses = %d %+v, want 2 calls with 1 near miss each", rec.Code, misses)
+	}
+	for _, q := range []string{"?limit=0", "?limit=x"} {
+		if rec := do(t, mux, "GET", "/__admin/near-misses"+q, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("near-misses%s = %d, want 400", q, rec.Code)
+		}
+	}
+
+	var groups []appdata.UnmatchedGroup
+	if do(t, mux, "GET", "/__admin/unmatched", "", &groups); len(groups) != 1 || groups[0].PathSignature != "/users/{id}" || groups[0].Count != 2 {
+		t.Fatalf("unmatched = %+v", groups)
+	}
+
+	var draft appdata.Mapping
+	if rec := do(t, mux, "POST", "/__admin/unmatched/stub", `{"method":"GET","pathSignature":"/users/42"}`, &draft); rec.Code != http.StatusOK || draft.Request.URLPattern != "/users/{id}" {
+		t.Fatalf("stub draft = %d %+v", rec.Code, draft)
+	}
+	if _, ok := appdata.GetMapping(draft.ID); ok {
+		t.Fatal("draft installed without install: true")
+	}
+	if rec := do(t, mux, "POST", "/__admin/unmatched/stub", `{"method":"GET","pathSignature":"/users/{id}","install":true}`, &draft); rec.Code != http.StatusCreated {
+		t.Fatalf("stub install = %d, want 201", rec.Code)
+	}
+	if _, ok := appdata.GetMapping(draft.ID); !ok {
+		t.Fatal("installed draft not registered")
+	}
+	if rec := do(t, mux, "POST", "/__admin/unmatched/stub", `{"method":"DELETE","pathSignature":"/users/1"}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("stub unknown group = %d, want 404", rec.Code)
+	}
+}
@@ -1,12 +1,18 @@
 package appdata
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/textproto"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Mapping is the JSON-defined stub configuration loaded at startup.
@@ -18,6 +24,21 @@ type Mapping struct {
 	Request     Request  `json:"request"`
 	Response    Response `json:"response"`
 	Metadata    Metadata `json:"metadata,omitempty"`
+
+	// Scenario state machine (optional). A mapping with a ScenarioName only
+	// matches while the scenario is in RequiredScenarioState (if set), and
+	// moves it to NewScenarioState (if set) when selected. Every scenario
+	// starts in ScenarioStarted.
+	ScenarioName          string `json:"scenarioName,omitempty"`
+	RequiredScenarioState string `json:"requiredScenarioState,omitempty"`
+	NewScenarioState      string `json:"newScenarioState,omitempty"`
+
+	// Responses replaces Response with a list served according to
+	// ResponseMode ("sequence", "cycle" or "weightedRandom"); see responses.go.
+	// ResponseSeed makes weightedRandom reproducible.
+	Responses    []Response `json:"responses,omitempty"`
+	ResponseMode string     `json:"responseMode,omitempty"`
+	ResponseSeed *int64     `json:"responseSeed,omitempty"`
 }
 
 type Metadata struct {
@@ -29,25 +50,71 @@ type Request struct {
 	Method     string `json:"method"`
 	URLPattern string `json:"urlPattern"`
 	// URLMatch controls how urlPattern is matched against the incoming request URL/path.
-	// Supported values: "contains" (default), "exact", "prefix", "regex".
+	// Supported values: "contains" (default), "exact", "prefix", "regex", "template".
+	// "template" patterns use {name} segments, e.g. "/users/{id}/orders/{orderId}".
 	URLMatch string `json:"urlMatch,omitempty"`
 
-	// QueryParams are required query key=value pairs.
-	// Example JSON: "queryParams": { "userId": "123", "source": "mobile" }
-	// All listed pairs must be present and equal in the incoming request.
-	QueryParams map[string]string `json:"queryParams,omitempty"`
-
-	// Body is a set of required JSON field path -> expected value.
-	// v1 semantics: all listed fields must exist and equal the expected value.
-	// Field paths use dot-notation (e.g. "customer.email").
+	// QueryParams are required query key -> matcher pairs.
+	// Example JSON: "queryParams": { "userId": "123", "source": { "oneOf": ["ios", "android"] } }
+	// A plain value is shorthand for {"equalTo": value}; see matchers.go for operators.
+	QueryParams map[string]any `json:"queryParams,omitempty"`
+	// ExactQuery rejects requests carrying query params that QueryParams
+	// does not list. Record mode sets it so a stub answers only its own query.
+	ExactQuery bool `json:"exactQuery,omitempty"`
+
+	// Headers are required header name -> matcher pairs.
+	// Example JSON: "headers": { "Authorization": { "matches": "^Bearer " }, "X-Tenant-Id": "acme" }
+	// Header names are matched case-insensitively.
+	Headers map[string]any `json:"headers,omitempty"`
+
+	// Body is a set of required JSON field path -> matcher.
+	// All listed matchers must hold; plain values must exist and be equal.
+	// Field paths use dot-notation with array segments (e.g. "customer.email",
+	// "items[0].sku", "items[*].type"); see dotPath.go. Keys starting with '$'
+	// are JSONPath expressions (e.g. "$.items[?(@.qty > 2)].sku"); see jsonPath.go.
 	Body map[string]any `json:"body,omitempty"`
+
+	// BodyEqualToJSON requires the whole body to equal a JSON document, e.g.
+	// "bodyEqualToJson": { "json": {...}, "ignoreExtraElements": true, "ignoreArrayOrder": true }
+	// It can be combined with Body field matchers.
+	BodyEqualToJSON *BodyEqualToJSON `json:"bodyEqualToJson,omitempty"`
 }
 
 type Response struct {
-	Status       int               `json:"status"`
-	Headers      map[string]string `json:"headers,omitempty"`
-	Body         any               `json:"body,omitempty"`
-	FixedDelayMs int               `json:"fixedDelayMs,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// MultiValueHeaders sends a header once per value, e.g. several Set-Cookie.
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              any                 `json:"body,omitempty"`
+	FixedDelayMs      int                 `json:"fixedDelayMs,omitempty"`
+	// DelayDistribution adds a random delay or dribbles the body; see delay.go.
+	DelayDistribution *DelayDistribution `json:"delayDistribution,omitempty"`
+
+	// Byte-for-byte body alternatives; at most one body field may be set.
+	// RawBody is sent as-is, Base64Body is decoded first, BodyFile is read from disk.
+	RawBody    string `json:"rawBody,omitempty"`
+	Base64Body string `json:"base64Body,omitempty"`
+	BodyFile   string `json:"bodyFile,omitempty"`
+
+	// Transform opts into request-driven templating of status, headers and
+	// body ("template"); see responseTemplate.go for the available data.
+	Transform string `json:"transform,omitempty"`
+	// StatusTemplate overrides Status when Transform is set, e.g. "{{query `code`}}".
+	StatusTemplate string `json:"statusTemplate,omitempty"`
+
+	// ProxyBaseURL forwards the request to this upstream instead of serving
+	// a canned body; see proxy.go.
+	ProxyBaseURL        string            `json:"proxyBaseUrl,omitempty"`
+	ProxyRequestHeaders map[string]string `json:"proxyRequestHeaders,omitempty"`
+	ProxyTimeoutMs      int               `json:"proxyTimeoutMs,omitempty"`
+
+	// Fault replaces the response with a transport-level failure
+	// (e.g. "CONNECTION_RESET_BY_PEER"); see faults.go.
+	Fault string `json:"fault,omitempty"`
+
+	// Weight is the relative chance of this entry in a weightedRandom
+	// responses list (default 1); 0 never picks it.
+	Weight *int `json:"weight,omitempty"`
 }
 
 // IncomingRequest is the normalized shape used to match a runtime stub.
@@ -57,69 +124,144 @@ type IncomingRequest struct {
 	// URL is typically the path (e.g. "/users/123/orders"). You can also pass full URL.
 	URL   string
 	Query map[string][]string
-	Body  any
+	// Headers holds the incoming request headers (net/http.Header is assignable).
+	Headers map[string][]string
+	Body    any
+	// RawBody is the body exactly as received, for proxying, as are
+	// EscapedPath and RawQuery.
+	RawBody     []byte
+	EscapedPath string
+	RawQuery    string
+	// RemoteAddr is the client's network address, for call history.
+	RemoteAddr string
 }
 
 // Global is the process-wide runtime index populated on startup.
 var Global = NewRuntimeIndex()
 
+// RuntimeIndex serves lookups from an immutable snapshot published through an
+// atomic pointer. Writers build a complete new snapshot off to the side and
+// swap it in, so lookups are lock-free and never observe a half-built index.
 type RuntimeIndex struct {
-	mu      sync.RWMutex
+	writeMu sync.Mutex // serializes writers; readers never lock
+	current atomic.Pointer[indexSnapshot]
+
+	scenarios scenarioStore
+	responses responseStore
+}
+
+// indexSnapshot is a fully built index. It is never mutated after publish.
+type indexSnapshot struct {
 	methods map[string]*methodNode
+	stubs   []*compiledStub // indexed stubs in load order
 	order   int64
-	count   int
+	// hasScenarios lets Match skip scenario bookkeeping when no stub uses it.
+	hasScenarios bool
+	// raw config of every accepted mapping in load order (including disabled
+	// ones), for admin APIs; kept here so it always agrees with the index.
+	mappings []Mapping
 }
 
-// in-memory snapshot of all loaded mappings (raw config), for admin APIs.
-var (
-	mappingsMu  sync.RWMutex
-	allMappings []Mapping
-)
-
 func NewRuntimeIndex() *RuntimeIndex {
-	return &RuntimeIndex{
-		methods: make(map[string]*methodNode),
-	}
+	ri := &RuntimeIndex{}
+	ri.current.Store(newSnapshot())
+	return ri
 }
 
-func (ri *RuntimeIndex) Reset() {
-	ri.mu.Lock()
-	defer ri.mu.Unlock()
-	ri.methods = make(map[string]*methodNode)
-	ri.order = 0
-	ri.count = 0
+func newSnapshot() *indexSnapshot {
+	return &indexSnapshot{methods: make(map[string]*methodNode)}
 }
 
-// ResetMappings clears the global mappings list.
-func ResetMappings() {
-	mappingsMu.Lock()
-	defer mappingsMu.Unlock()
-	allMappings = nil
+func (ri *RuntimeIndex) snapshot() *indexSnapshot {
+	return ri.current.Load()
 }
 
-// RegisterMapping adds a mapping to the global list for admin/introspection.
-func RegisterMapping(m Mapping) {
-	mappingsMu.Lock()
-	defer mappingsMu.Unlock()
-	allMappings = append(allMappings, m)
+// Reset drops every mapping along with the scenario states and responses
+// positions kept for them.
+func (ri *RuntimeIndex) Reset() {
+	ri.writeMu.Lock()
+	defer ri.writeMu.Unlock()
+	ri.current.Store(newSnapshot())
+	ri.scenarios.resetAll()
+	ri.ResetResponses()
 }
 
 // GetAllMappings returns a copy of all registered mappings.
 func GetAllMappings() []Mapping {
-	mappingsMu.RLock()
-	defer mappingsMu.RUnlock()
-	out := make([]Mapping, len(allMappings))
-	copy(out, allMappings)
+	return Global.Mappings()
+}
+
+// Mappings returns a copy of the raw mappings in the current snapshot.
+func (ri *RuntimeIndex) Mappings() []Mapping {
+	snap := ri.snapshot()
+	out := make([]Mapping, len(snap.mappings))
+	copy(out, snap.mappings)
 	return out
 }
 
 func (ri *RuntimeIndex) Count() int {
-	ri.mu.RLock()
-	defer ri.mu.RUnlock()
-	return ri.count
+	return len(ri.snapshot().stubs)
 }
 
+// Add publishes a new snapshot containing the current stubs plus m.
+// Prefer Replace when loading many mappings at once.
 func (ri *RuntimeIndex) Add(m Mapping) error {
+	ri.writeMu.Lock()
+	defer ri.writeMu.Unlock()
+
+	next := ri.snapshot().clone()
+	if err := next.add(m, nil); err != nil {
+		return err
+	}
+	ri.current.Store(next)
+	return nil
+}
+
+// Replace builds a snapshot from the mappings returned by change (which
+// receives a copy of the current ones) and publishes it in one step. Mappings
+// that did not change keep their compiled stubs; only the tree is rebuilt. On
+// error the current snapshot stays in place.
+func (ri *RuntimeIndex) Replace(change func(current []Mapping) ([]Mapping, error)) error {
+	ri.writeMu.Lock()
+	defer ri.writeMu.Unlock()
+
+	current := ri.snapshot()
+	list, err := change(ri.Mappings())
+	if err != nil {
+		return err
+	}
+	compiled := make(map[string]*compiledStub, len(current.stubs))
+	for _, cs := range current.stubs {
+		if _, dup := compiled[cs.mapping.ID]; !dup {
+			compiled[cs.mapping.ID] = cs
+		}
+	}
+	next := newSnapshot()
+	for _, m := range list {
+		if err := next.add(m, compiled[m.ID]); err != nil {
+			return &ValidationError{Err: err}
+		}
+	}
+	ri.current.Store(next)
+	return nil
+}
+
+// clone copies the tree structure (compiled stubs are immutable and shared).
+func (s *indexSnapshot) clone() *indexSnapshot {
+	next := newSnapshot()
+	next.order = s.order
+	next.mappings = append(make([]Mapping, 0, len(s.mappings)+1), s.mappings...)
+	for _, cs := range s.stubs {
+		next.insert(cs)
+	}
+	return next
+}
+
+// add validates, compiles and inserts m. prev (optional) is a stub compiled
+// earlier for the same id; it is reused when raw did not change. Only called
+// on unpublished snapshots.
+func (s *indexSnapshot) add(raw Mapping, prev *compiledStub) error {
+	m := raw
 	if strings.TrimSpace(m.ID) == "" {
 		return errors.New("missing mapping.id")
 	}
@@ -138,52 +280,124 @@ func (ri *RuntimeIndex) Add(m Mapping) error {
 		enabled = *m.Metadata.Enabled
 	}
 	if !enabled {
-		// keep it out of the runtime index entirely; we can change this later if you want hot toggles.
+		// keep it out of the runtime index entirely; it is still listed for admin APIs.
+		s.mappings = append(s.mappings, raw)
 		return nil
 	}
 
-	ri.mu.Lock()
-	ri.order++
-	order := ri.order
-	ri.mu.Unlock()
-
-	cs, err := compileStub(m, order)
-	if err != nil {
-		return err
+	var cs *compiledStub
+	if prev != nil && reflect.DeepEqual(prev.raw, raw) {
+		// Compiled stubs are immutable; only the load order moves.
+		reused := *prev
+		reused.order = s.order + 1
+		cs = &reused
+	} else {
+		var err error
+		if cs, err = compileStub(m, s.order+1); err != nil {
+			return err
+		}
+		cs.raw = raw
 	}
+	s.order++
+	s.insert(cs)
+	s.mappings = append(s.mappings, raw)
+	return nil
+}
 
-	ri.mu.Lock()
-	defer ri.mu.Unlock()
-
-	mn := ri.methods[m.Request.Method]
+func (s *indexSnapshot) insert(cs *compiledStub) {
+	mn := s.methods[cs.mapping.Request.Method]
 	if mn == nil {
 		mn = &methodNode{}
-		ri.methods[m.Request.Method] = mn
+		s.methods[cs.mapping.Request.Method] = mn
 	}
 	mn.addCompiled(cs)
-	ri.count++
-	return nil
+	s.stubs = append(s.stubs, cs)
+	if cs.mapping.ScenarioName != "" {
+		s.hasScenarios = true
+	}
 }
 
-// FindBestMatch matches a request to the best stub based on:
-// priority asc (lower wins) -> specificity score desc -> load order asc.
-func (ri *RuntimeIndex) FindBestMatch(req IncomingRequest) (Mapping, bool) {
-	method := strings.ToUpper(strings.TrimSpace(req.Method))
+// MatchResult is the outcome of a successful match.
+type MatchResult struct {
+	Mapping Mapping
+	// PathParams holds values captured by a "template" urlPattern (nil otherwise).
+	PathParams map[string]string
 
-	ri.mu.RLock()
-	mn := ri.methods[method]
-	ri.mu.RUnlock()
-	if mn == nil {
-		return Mapping{}, false
+	stub     *compiledStub
+	response int // index into stub.responses
+}
+
+// Render returns the response to send for req, applying response templating
+// when the mapping opted in. Without a transform the configured response is
+// returned unchanged.
+func (r MatchResult) Render(req IncomingRequest) (Response, error) {
+	if r.stub == nil {
+		return r.Mapping.Response, nil
 	}
+	resp := r.stub.responses[r.response]
+	rt := r.stub.responseTemplates[r.response]
+	if rt == nil {
+		return resp, nil
+	}
+	return rt.render(resp, &templateData{
+		Method:     strings.ToUpper(req.Method),
+		Path:       req.URL,
+		PathParams: r.PathParams,
+		Query:      req.Query,
+		Headers:    req.Headers,
+		Body:       req.Body,
+	})
+}
 
-	best, ok := mn.findBest(req)
+// FindBestMatch matches a request to the best stub based on:
+// priority asc (lower wins) -> specificity score desc -> load order asc.
+// It only looks: scenarios and responses lists stay where they are.
+func (ri *RuntimeIndex) FindBestMatch(req IncomingRequest) (Mapping, bool) {
+	best, _, _, ok := ri.find(ri.snapshot(), req)
 	if !ok {
 		return Mapping{}, false
 	}
 	return best.mapping, true
 }
 
+// Match serves req: like FindBestMatch, it also applies the stub's scenario
+// transition, advances its responses list and returns the request data
+// captured while matching.
+func (ri *RuntimeIndex) Match(req IncomingRequest) (MatchResult, bool) {
+	snap := ri.snapshot()
+	for {
+		best, params, states, ok := ri.find(snap, req)
+		if !ok {
+			return MatchResult{}, false
+		}
+
+		m := best.mapping
+		if m.ScenarioName != "" && m.NewScenarioState != "" {
+			// Another request may have moved the scenario since states was
+			// copied; if so, match again against the new state.
+			if !ri.scenarios.transition(m.ScenarioName, scenarioStateOf(states, m.ScenarioName), m.NewScenarioState) {
+				continue
+			}
+		}
+		return MatchResult{Mapping: m, PathParams: params, stub: best, response: ri.responses.pick(best)}, true
+	}
+}
+
+// find picks the best stub in snap under the current scenario states and
+// returns those states with it.
+func (ri *RuntimeIndex) find(snap *indexSnapshot, req IncomingRequest) (*compiledStub, map[string]string, map[string]string, bool) {
+	mn := snap.methods[strings.ToUpper(strings.TrimSpace(req.Method))]
+	if mn == nil {
+		return nil, nil, nil, false
+	}
+	var states map[string]string
+	if snap.hasScenarios {
+		states = ri.scenarios.copyStates()
+	}
+	best, params, ok := mn.findBest(req, states)
+	return best, params, states, ok
+}
+
 // ---- internal tree nodes ----
 
 type methodNode struct {
@@ -195,9 +409,9 @@ func (mn *methodNode) addCompiled(cs *compiledStub) {
 	// URL level
 	un := mn.findOrCreateURLNode(cs)
 	// Query level
-	qn := un.findOrCreateQueryNode(cs.querySignature, cs.queryPairs)
+	qn := un.findOrCreateQueryNode(cs.querySignature, cs.queryMatchers, cs.mapping.Request.ExactQuery)
 	// Body level
-	bn := qn.findOrCreateBodyNode(cs.bodySignature, cs.bodyMatchers)
+	bn := qn.findOrCreateBodyNode(cs.bodySignature, cs.bodyMatchers, cs.bodyEqualJSON)
 	bn.stubs = append(bn.stubs, cs)
 }
 
@@ -209,22 +423,29 @@ func (mn *methodNode) findOrCreateURLNode(cs *compiledStub) *urlNode {
 		}
 	}
 	n := &urlNode{
-		key:     key,
-		regex:   cs.regex,
-		queries: make(map[string]*queryNode),
+		key:      key,
+		regex:    cs.regex,
+		template: cs.template,
+		queries:  make(map[string]*queryNode),
 	}
 	mn.urls = append(mn.urls, n)
 	return n
 }
 
-func (mn *methodNode) findBest(req IncomingRequest) (*compiledStub, bool) {
-	var best *compiledStub
+// findBest picks the winning stub; states holds current scenario states
+// (nil when no stub in the snapshot uses scenarios).
+func (mn *methodNode) findBest(req IncomingRequest, states map[string]string) (*compiledStub, map[string]string, bool) {
+	var (
+		best       *compiledStub
+		bestParams map[string]string
+	)
 	bestPriority := int(^uint(0) >> 1) // max int
 	bestScore := -1
 	var bestOrder int64 = 1<<63 - 1
 
 	for _, un := range mn.urls {
-		if !un.matchesURL(req.URL) {
+		params, ok := un.matchURL(req.URL)
+		if !ok {
 			continue
 		}
 		for _, qn := range un.queries {
@@ -236,12 +457,16 @@ func (mn *methodNode) findBest(req IncomingRequest) (*compiledStub, bool) {
 					continue
 				}
 				for _, cs := range bn.stubs {
+					if !cs.matchesHeaders(req.Headers) || !cs.matchesScenario(states) {
+						continue
+					}
 					score := cs.specificityScore()
 					p := cs.mapping.Priority
 					if p < bestPriority ||
 						(p == bestPriority && score > bestScore) ||
 						(p == bestPriority && score == bestScore && cs.order < bestOrder) {
 						best = cs
+						bestParams = params
 						bestPriority = p
 						bestScore = score
 						bestOrder = cs.order
@@ -251,7 +476,7 @@ func (mn *methodNode) findBest(req IncomingRequest) (*compiledStub, bool) {
 		}
 	}
 
-	return best, best != nil
+	return best, bestParams, best != nil
 }
 
 type urlKey struct {
@@ -260,26 +485,32 @@ type urlKey struct {
 }
 
 type urlNode struct {
-	key   urlKey
-	regex *regexp.Regexp
+	key      urlKey
+	regex    *regexp.Regexp
+	template *pathTemplate
 	// querySignature -> queryNode
 	queries map[string]*queryNode
 }
 
-func (un *urlNode) matchesURL(u string) bool {
+// matchURL reports whether u matches and returns any template path params.
+func (un *urlNode) matchURL(u string) (map[string]string, bool) {
+	if un.key.kind == urlMatchTemplate && un.template != nil {
+		return un.template.match(u)
+	}
 	if un.key.kind == urlMatchRegex && un.regex != nil {
-		return un.regex.MatchString(u)
+		return nil, un.regex.MatchString(u)
 	}
-	return un.key.kind.match(un.key.pattern, u)
+	return nil, un.key.kind.match(un.key.pattern, u)
 }
 
-func (un *urlNode) findOrCreateQueryNode(sig string, pairs []queryPair) *queryNode {
+func (un *urlNode) findOrCreateQueryNode(sig string, matchers []keyMatcher, exact bool) *queryNode {
 	if existing := un.queries[sig]; existing != nil {
 		return existing
 	}
 	n := &queryNode{
 		signature: sig,
-		required:  pairs,
+		required:  matchers,
+		exact:     exact,
 		bodies:    make(map[string]*bodyNode),
 	}
 	un.queries[sig] = n
@@ -288,33 +519,44 @@ func (un *urlNode) findOrCreateQueryNode(sig string, pairs []queryPair) *queryNo
 
 type queryNode struct {
 	signature string
-	required  []queryPair // sorted
+	required  []keyMatcher // sorted
+	exact     bool         // no other query params allowed
 	bodies    map[string]*bodyNode
 }
 
 func (qn *queryNode) matchesQuery(query map[string][]string) bool {
-	if len(qn.required) == 0 {
-		return true
-	}
-	for _, p := range qn.required {
-		values, ok := query[p.Key]
-		if !ok {
+	for _, km := range qn.required {
+		values, ok := query[km.Key]
+		if !km.matchValues(values, ok) {
 			return false
 		}
-		if !containsString(values, p.Value) {
-			return false
+	}
+	return len(qn.extraParams(query)) == 0
+}
+
+// extraParams returns the sorted query params an exact node does not list.
+func (qn *queryNode) extraParams(query map[string][]string) []string {
+	if !qn.exact {
+		return nil
+	}
+	var extra []string
+	for k := range query {
+		if !slices.ContainsFunc(qn.required, func(km keyMatcher) bool { return km.Key == k }) {
+			extra = append(extra, k)
 		}
 	}
-	return true
+	sort.Strings(extra)
+	return extra
 }
 
-func (qn *queryNode) findOrCreateBodyNode(sig string, matchers []bodyFieldMatcher) *bodyNode {
+func (qn *queryNode) findOrCreateBodyNode(sig string, matchers []bodyFieldMatcher, equalJSON *jsonEqualMatcher) *bodyNode {
 	if existing := qn.bodies[sig]; existing != nil {
 		return existing
 	}
 	n := &bodyNode{
 		signature: sig,
 		matchers:  matchers,
+		equalJSON: equalJSON,
 	}
 	qn.bodies[sig] = n
 	return n
@@ -323,12 +565,13 @@ func (qn *queryNode) findOrCreateBodyNode(sig string, matchers []bodyFieldMatche
 type bodyNode struct {
 	signature string
 	matchers  []bodyFieldMatcher
+	equalJSON *jsonEqualMatcher // optional whole-body equality
 	stubs     []*compiledStub
 }
 
 func (bn *bodyNode) matchesBody(body any) bool {
-	if len(bn.matchers) == 0 {
-		return true
+	if bn.equalJSON != nil && !bn.equalJSON.match(body) {
+		return false
 	}
 	for _, m := range bn.matchers {
 		if !m.match(body) {
@@ -347,6 +590,7 @@ const (
 	urlMatchExact
 	urlMatchPrefix
 	urlMatchRegex
+	urlMatchTemplate
 )
 
 func parseURLMatchKind(s string) urlMatchKind {
@@ -359,20 +603,37 @@ func parseURLMatchKind(s string) urlMatchKind {
 		return urlMatchPrefix
 	case "regex":
 		return urlMatchRegex
+	case "template":
+		return urlMatchTemplate
 	default:
 		// be strict by default: treat unknown as contains
 		return urlMatchContains
 	}
 }
 
+func (k urlMatchKind) String() string {
+	switch k {
+	case urlMatchExact:
+		return "exact"
+	case urlMatchPrefix:
+		return "prefix"
+	case urlMatchRegex:
+		return "regex"
+	case urlMatchTemplate:
+		return "template"
+	default:
+		return "contains"
+	}
+}
+
 func (k urlMatchKind) match(pattern, u string) bool {
 	switch k {
 	case urlMatchExact:
 		return u == pattern
 	case urlMatchPrefix:
 		return strings.HasPrefix(u, pattern)
-	case urlMatchRegex:
-		// Regex matching is handled by urlNode (which holds the compiled regex).
+	case urlMatchRegex, urlMatchTemplate:
+		// Regex/template matching is handled by urlNode (which holds the compiled form).
 		// Fallback to a safe contains match if called unexpectedly.
 		return strings.Contains(u, pattern)
 	case urlMatchContains:
@@ -382,33 +643,55 @@ func (k urlMatchKind) match(pattern, u string) bool {
 	}
 }
 
+// bodyFieldMatcher checks one body requirement. Keys starting with '$' are
+// JSONPath expressions; everything else is a dot path.
 type bodyFieldMatcher struct {
 	path     string
+	segments []pathSegment
+	jsonPath *jsonPath
 	expected any
+	m        valueMatcher
 }
 
 func (m bodyFieldMatcher) match(body any) bool {
-	v, ok := lookupDotPath(body, m.path)
-	if !ok {
+	if m.jsonPath != nil {
+		// The matcher passes if any selected node satisfies it; an empty
+		// selection is reported as a missing value (so "absent" works).
+		nodes := m.jsonPath.eval(body)
+		if len(nodes) == 0 {
+			return m.m.match(nil, false)
+		}
+		for _, n := range nodes {
+			if m.m.match(n, true) {
+				return true
+			}
+		}
 		return false
 	}
-	return valuesEqual(v, m.expected)
+	return matchPath(body, m.segments, m.m)
 }
 
-func lookupDotPath(body any, path string) (any, bool) {
-	cur := body
-	for _, part := range strings.Split(path, ".") {
-		obj, ok := cur.(map[string]any)
-		if !ok {
-			return nil, false
+func compileBodyFieldMatcher(path string, expected any) (bodyFieldMatcher, error) {
+	bm := bodyFieldMatcher{path: path, expected: expected}
+	if strings.HasPrefix(path, "$") {
+		jp, err := compileJSONPath(path)
+		if err != nil {
+			return bodyFieldMatcher{}, err
 		}
-		next, ok := obj[part]
-		if !ok {
-			return nil, false
+		bm.jsonPath = jp
+	} else {
+		segs, err := parseDotPath(path)
+		if err != nil {
+			return bodyFieldMatcher{}, err
 		}
-		cur = next
+		bm.segments = segs
+	}
+	vm, err := compileValueMatcher(expected)
+	if err != nil {
+		return bodyFieldMatcher{}, fmt.Errorf("%q: %w", path, err)
 	}
-	return cur, true
+	bm.m = vm
+	return bm, nil
 }
 
 func valuesEqual(actual, expected any) bool {
@@ -436,33 +719,79 @@ func valuesEqual(actual, expected any) bool {
 	return fmt.Sprint(actual) == fmt.Sprint(expected)
 }
 
-func containsString(values []string, want string) bool {
-	for _, v := range values {
-		if v == want {
-			return true
+// headerValues looks up a header case-insensitively. Canonical keys (as produced
+// by net/http) hit the fast path; anything else falls back to a linear scan.
+func headerValues(headers map[string][]string, name string) ([]string, bool) {
+	if v, ok := headers[textproto.CanonicalMIMEHeaderKey(name)]; ok {
+		return v, true
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
 		}
 	}
-	return false
+	return nil, false
 }
 
 type compiledStub struct {
 	mapping Mapping
 	order   int64
+	// raw is the mapping as configured, before defaults are applied; Replace
+	// compares it to decide whether cs can be reused.
+	raw Mapping
 
-	urlKind urlMatchKind
-	pattern string
-	regex   *regexp.Regexp
+	urlKind  urlMatchKind
+	pattern  string
+	regex    *regexp.Regexp
+	template *pathTemplate
 
-	queryPairs     []queryPair
+	queryMatchers  []keyMatcher
 	querySignature string
 
 	bodyMatchers  []bodyFieldMatcher
+	bodyEqualJSON *jsonEqualMatcher
 	bodySignature string
+
+	headerMatchers []keyMatcher // names in canonical form
+
+	// responses holds the configured response (or the responses list);
+	// responseTemplates runs parallel to it, nil where no transform is set.
+	responses         []Response
+	responseTemplates []*responseTemplate
+}
+
+func (cs *compiledStub) matchesScenario(states map[string]string) bool {
+	m := cs.mapping
+	if m.ScenarioName == "" || m.RequiredScenarioState == "" {
+		return true
+	}
+	return scenarioStateOf(states, m.ScenarioName) == m.RequiredScenarioState
 }
 
-type queryPair struct {
-	Key   string
-	Value string
+// matches checks req against every request constraint of cs without going
+// through the index tree; used to verify recorded calls. An empty method
+// matches any method.
+func (cs *compiledStub) matches(req IncomingRequest) bool {
+	if m := cs.mapping.Request.Method; m != "" && !strings.EqualFold(m, req.Method) {
+		return false
+	}
+	un := urlNode{key: cs.urlKey(), regex: cs.regex, template: cs.template}
+	if _, ok := un.matchURL(req.URL); !ok {
+		return false
+	}
+	qn := queryNode{required: cs.queryMatchers, exact: cs.mapping.Request.ExactQuery}
+	bn := bodyNode{matchers: cs.bodyMatchers, equalJSON: cs.bodyEqualJSON}
+	return qn.matchesQuery(req.Query) && bn.matchesBody(req.Body) && cs.matchesHeaders(req.Headers)
+}
+
+func (cs *compiledStub) matchesHeaders(headers map[string][]string) bool {
+	for _, km := range cs.headerMatchers {
+		values, ok := headerValues(headers, km.Key)
+		if !km.matchValues(values, ok) {
+			return false
+		}
+	}
+	return true
 }
 
 func (cs *compiledStub) urlKey() urlKey {
@@ -474,6 +803,8 @@ func (cs *compiledStub) specificityScore() int {
 	switch cs.urlKind {
 	case urlMatchExact:
 		score += 1000
+	case urlMatchTemplate:
+		score += 900
 	case urlMatchPrefix:
 		score += 800
 	case urlMatchContains:
@@ -481,10 +812,19 @@ func (cs *compiledStub) specificityScore() int {
 	case urlMatchRegex:
 		score += 400
 	}
-	// More literal characters usually means more specific.
-	score += min(len(cs.pattern), 200)
-	score += 10 * len(cs.queryPairs)
+	// More literal characters usually means more specific, but never enough
+	// to outrank a stricter match kind.
+	if cs.template != nil {
+		score += min(cs.template.literals, 99)
+	} else {
+		score += min(len(cs.pattern), 99)
+	}
+	score += 10 * len(cs.queryMatchers)
+	score += 10 * len(cs.headerMatchers)
 	score += 20 * len(cs.bodyMatchers)
+	if cs.bodyEqualJSON != nil {
+		score += 200
+	}
 	return score
 }
 
@@ -505,35 +845,43 @@ func compileStub(m Mapping, order int64) (*compiledStub, error) {
 		}
 		cs.regex = re
 	}
+	if kind == urlMatchTemplate {
+		pt, err := compilePathTemplate(m.Request.URLPattern)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %q: invalid urlPattern: %w", m.ID, err)
+		}
+		cs.template = pt
+	}
 
-	// Query signature: sort key=value pairs for determinism.
+	// Query signature: sort key=matcher pairs for determinism.
 	if len(m.Request.QueryParams) > 0 {
-		pairs := make([]queryPair, 0, len(m.Request.QueryParams))
-		for k, v := range m.Request.QueryParams {
-			k = strings.TrimSpace(k)
-			v = strings.TrimSpace(v)
-			if k == "" {
-				continue
-			}
-			pairs = append(pairs, queryPair{Key: k, Value: v})
+		matchers, err := compileKeyMatchers(m.Request.QueryParams, nil)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %q: queryParams %w", m.ID, err)
 		}
-		sort.Slice(pairs, func(i, j int) bool {
-			if pairs[i].Key == pairs[j].Key {
-				return pairs[i].Value < pairs[j].Value
-			}
-			return pairs[i].Key < pairs[j].Key
-		})
-		cs.queryPairs = pairs
+		cs.queryMatchers = matchers
 
-		sigParts := make([]string, 0, len(pairs))
-		for _, p := range pairs {
-			sigParts = append(sigParts, fmt.Sprintf("%s=%s", p.Key, p.Value))
+		sigParts := make([]string, 0, len(matchers))
+		for _, km := range matchers {
+			sigParts = append(sigParts, km.signature())
 		}
 		cs.querySignature = strings.Join(sigParts, "&")
 	} else {
-		cs.queryPairs = nil
+		cs.queryMatchers = nil
 		cs.querySignature = ""
 	}
+	if m.Request.ExactQuery {
+		cs.querySignature = "exact|" + cs.querySignature
+	}
+
+	// Header requirements: canonicalize names so lookups are case-insensitive.
+	if len(m.Request.Headers) > 0 {
+		matchers, err := compileKeyMatchers(m.Request.Headers, textproto.CanonicalMIMEHeaderKey)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %q: headers %w", m.ID, err)
+		}
+		cs.headerMatchers = matchers
+	}
 
 	// Body matchers
 	if len(m.Request.Body) > 0 {
@@ -545,17 +893,78 @@ func compileStub(m Mapping, order int64) (*compiledStub, error) {
 		var sigParts []string
 		for _, p := range paths {
 			exp := m.Request.Body[p]
-			cs.bodyMatchers = append(cs.bodyMatchers, bodyFieldMatcher{path: p, expected: exp})
-			sigParts = append(sigParts, fmt.Sprintf("%s=%s", p, fmt.Sprint(exp)))
+			bm, err := compileBodyFieldMatcher(p, exp)
+			if err != nil {
+				return nil, fmt.Errorf("mapping %q: body %w", m.ID, err)
+			}
+			cs.bodyMatchers = append(cs.bodyMatchers, bm)
+			sigParts = append(sigParts, canonicalJSON(p)+"="+canonicalJSON(exp))
 		}
 		cs.bodySignature = strings.Join(sigParts, "|")
 	} else {
 		cs.bodySignature = ""
 	}
 
+	// Whole-body equality shares the body level; fold it into the signature.
+	equalJSON, err := compileJSONEqual(m.Request.BodyEqualToJSON)
+	if err != nil {
+		return nil, fmt.Errorf("mapping %q: %w", m.ID, err)
+	}
+	if equalJSON != nil {
+		cs.bodyEqualJSON = equalJSON
+		cs.bodySignature += "#" + equalJSON.signature()
+	}
+
+	if err := cs.compileResponses(); err != nil {
+		return nil, fmt.Errorf("mapping %q: %w", m.ID, err)
+	}
+
 	return cs, nil
 }
 
+// validateResponseBody rejects ambiguous or undecodable body configurations.
+func validateResponseBody(resp Response) error {
+	set := 0
+	for _, present := range []bool{resp.Body != nil, resp.RawBody != "", resp.Base64Body != "", resp.BodyFile != ""} {
+		if present {
+			set++
+		}
+	}
+	if set > 1 {
+		return errors.New("response: only one of body, rawBody, base64Body, bodyFile may be set")
+	}
+	if resp.ProxyBaseURL != "" {
+		if set > 0 {
+			return errors.New("response: proxyBaseUrl cannot be combined with a body")
+		}
+		if resp.Fault != "" {
+			return errors.New("response: proxyBaseUrl cannot be combined with a fault")
+		}
+		if err := validateProxyURL(resp.ProxyBaseURL); err != nil {
+			return fmt.Errorf("response.proxyBaseUrl: %w", err)
+		}
+	}
+	if resp.ProxyTimeoutMs < 0 {
+		return errors.New("response.proxyTimeoutMs must not be negative")
+	}
+	for k := range resp.MultiValueHeaders {
+		for h := range resp.Headers {
+			if textproto.CanonicalMIMEHeaderKey(h) == textproto.CanonicalMIMEHeaderKey(k) {
+				return fmt.Errorf("response: header %q is set in both headers and multiValueHeaders", k)
+			}
+		}
+	}
+	if resp.BodyFile != "" && !filepath.IsLocal(filepath.FromSlash(resp.BodyFile)) {
+		return fmt.Errorf("response.bodyFile %q must be a relative path inside the mocks directory", resp.BodyFile)
+	}
+	if resp.Base64Body != "" {
+		if _, err := base64.StdEncoding.DecodeString(resp.Base64Body); err != nil {
+			return fmt.Errorf("response.base64Body: %w", err)
+		}
+	}
+	return nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -1,6 +1,11 @@
 package appdata
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func boolPtr(b bool) *bool { return &b }
 
@@ -17,7 +22,7 @@ func TestRuntimeIndexAddAndFindBasic(t *testing.T) {
 			Method:     "POST",
 			URLPattern: urlPath,
 			// match userId=123
-			QueryParams: map[string]string{
+			QueryParams: map[string]any{
 				"userId": "123",
 			},
 			Body: map[string]any{
@@ -34,7 +39,7 @@ func TestRuntimeIndexAddAndFindBasic(t *testing.T) {
 			Method:     "POST",
 			URLPattern: "/users_orders",
 			// match userId=456
-			QueryParams: map[string]string{
+			QueryParams: map[string]any{
 				"userId": "456",
 			},
 			Body: map[string]any{
@@ -179,3 +184,271 @@ func TestRuntimeIndexDisabledMappingIgnored(t *testing.T) {
 		t.Fatalf("FindBestMatch on disabled mapping returned a match, want no match")
 	}
 }
+
+// Test that matchers which print alike with fmt.Sprint still get their own
+// query and body nodes, so each stub matches its own request.
+func TestRuntimeIndexDistinguishesSimilarMatchers(t *testing.T) {
+	ri := NewRuntimeIndex()
+	matchers := map[string]any{
+		"phrase": map[string]any{"oneOf": []any{"New York"}},
+		"words":  map[string]any{"oneOf": []any{"New", "York"}},
+	}
+	for id, exp := range matchers {
+		for _, m := range []Mapping{
+			{ID: "query-" + id, Request: Request{Method: "GET", URLPattern: "/cities", QueryParams: map[string]any{"city": exp}}},
+			{ID: "body-" + id, Request: Request{Method: "POST", URLPattern: "/cities", Body: map[string]any{"city": exp}}},
+		} {
+			if err := ri.Add(m); err != nil {
+				t.Fatalf("Add(%s) error = %v", m.ID, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		req  IncomingRequest
+		want string
+	}{
+		{IncomingRequest{Method: "GET", URL: "/cities", Query: map[string][]string{"city": {"New York"}}}, "query-phrase"},
+		{IncomingRequest{Method: "GET", URL: "/cities", Query: map[string][]string{"city": {"York"}}}, "query-words"},
+		{IncomingRequest{Method: "POST", URL: "/cities", Body: map[string]any{"city": "New York"}}, "body-phrase"},
+		{IncomingRequest{Method: "POST", URL: "/cities", Body: map[string]any{"city": "York"}}, "body-words"},
+	}
+	for _, tt := range tests {
+		res, ok := ri.FindBestMatch(tt.req)
+		if !ok || res.ID != tt.want {
+			t.Errorf("FindBestMatch(%s %v %v) = %q, %v, want %q", tt.req.Method, tt.req.Query, tt.req.Body, res.ID, ok, tt.want)
+		}
+	}
+}
+
+// Test that header constraints are matched case-insensitively and make a
+// stub more specific than one without them.
+func TestRuntimeIndexHeaderMatching(t *testing.T) {
+	ri := NewRuntimeIndex()
+
+	generic := Mapping{
+		ID: "generic",
+		Request: Request{
+			Method:     "GET",
+			URLPattern: "/orders",
+		},
+		Response: Response{Status: 200},
+	}
+	tenant := Mapping{
+		ID: "tenant",
+		Request: Request{
+			Method:     "GET",
+			URLPattern: "/orders",
+			Headers: map[string]any{
+				"x-tenant-id": "acme",
+			},
+		},
+		Response: Response{Status: 200},
+	}
+
+	if err := ri.Add(generic); err != nil {
+		t.Fatalf("Add(generic) error = %v", err)
+	}
+	if err := ri.Add(tenant); err != nil {
+		t.Fatalf("Add(tenant) error = %v", err)
+	}
+
+	req := IncomingRequest{
+		Method:  "GET",
+		URL:     "/orders",
+		Headers: map[string][]string{"X-Tenant-Id": {"acme"}},
+	}
+	got, ok := ri.FindBestMatch(req)
+	if !ok || got.ID != "tenant" {
+		t.Fatalf("FindBestMatch(acme).ID = %q, %v, want %q", got.ID, ok, "tenant")
+	}
+
+	req.Headers = map[string][]string{"X-Tenant-Id": {"other"}}
+	got, ok = ri.FindBestMatch(req)
+	if !ok || got.ID != "generic" {
+		t.Fatalf("FindBestMatch(other).ID = %q, %v, want %q", got.ID, ok, "generic")
+	}
+}
+
+// Test that template patterns capture path params and rank between exact
+// and prefix matches.
+func TestRuntimeIndexTemplateMatch(t *testing.T) {
+	ri := NewRuntimeIndex()
+
+	mappings := []Mapping{
+		{ID: "prefix", Request: Request{Method: "GET", URLPattern: "/users/", URLMatch: "prefix"}},
+		{ID: "template", Request: Request{Method: "GET", URLPattern: "/users/{id}/orders/{orderId}", URLMatch: "template"}},
+		{ID: "exact", Request: Request{Method: "GET", URLPattern: "/users/42/orders/7", URLMatch: "exact"}},
+	}
+	for _, m := range mappings {
+		if err := ri.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", m.ID, err)
+		}
+	}
+
+	res, ok := ri.Match(IncomingRequest{Method: "GET", URL: "/users/1/orders/99"})
+	if !ok || res.Mapping.ID != "template" {
+		t.Fatalf("Match(/users/1/orders/99) = %q, %v, want template", res.Mapping.ID, ok)
+	}
+	if res.PathParams["id"] != "1" || res.PathParams["orderId"] != "99" {
+		t.Fatalf("PathParams = %v, want id=1 orderId=99", res.PathParams)
+	}
+
+	if got, _ := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/users/42/orders/7"}); got.ID != "exact" {
+		t.Fatalf("FindBestMatch(exact path).ID = %q, want exact", got.ID)
+	}
+	if got, _ := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/users/1/orders"}); got.ID != "prefix" {
+		t.Fatalf("FindBestMatch(/users/1/orders).ID = %q, want prefix", got.ID)
+	}
+
+	bad := Mapping{ID: "bad", Request: Request{Method: "GET", URLPattern: "/users/id-{id}", URLMatch: "template"}}
+	if err := ri.Add(bad); err == nil {
+		t.Fatalf("Add(bad template) error = nil, want error")
+	}
+}
+
+// Test that an exactQuery stub, as record mode writes them, only answers
+// requests carrying exactly its query params.
+func TestRuntimeIndexExactQuery(t *testing.T) {
+	ri := NewRuntimeIndex()
+	if err := ri.Add(Mapping{
+		ID:      "search",
+		Request: Request{Method: "GET", URLPattern: "/search", URLMatch: "exact", QueryParams: map[string]any{"page": "1"}, ExactQuery: true},
+	}); err != nil {
+		t.Fatalf("Add(search) error = %v", err)
+	}
+
+	if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/search", Query: map[string][]string{"page": {"1"}}}); !ok {
+		t.Fatal("FindBestMatch(page=1) = no match, want search")
+	}
+	extra := IncomingRequest{Method: "GET", URL: "/search", Query: map[string][]string{"page": {"1"}, "q": {"foo"}}}
+	if _, ok := ri.FindBestMatch(extra); ok {
+		t.Fatal("FindBestMatch(page=1&q=foo) matched, want no match")
+	}
+	if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/search"}); ok {
+		t.Fatal("FindBestMatch(no query) matched, want no match")
+	}
+}
+
+// Test that a long prefix pattern does not outrank a short template on the
+// same path.
+func TestRuntimeIndexTemplateBeatsLongPrefix(t *testing.T) {
+	ri := NewRuntimeIndex()
+
+	segment := strings.Repeat("x", 150)
+	mappings := []Mapping{
+		{ID: "prefix", Request: Request{Method: "GET", URLPattern: "/files/" + segment, URLMatch: "prefix"}},
+		{ID: "template", Request: Request{Method: "GET", URLPattern: "/files/{name}", URLMatch: "template"}},
+	}
+	for _, m := range mappings {
+		if err := ri.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", m.ID, err)
+		}
+	}
+
+	if got, _ := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/files/" + segment}); got.ID != "template" {
+		t.Fatalf("FindBestMatch(long path).ID = %q, want template", got.ID)
+	}
+}
+
+// Test that Replace keeps the compiled stubs of unchanged mappings, compiles
+// changed ones afresh and renumbers the load order.
+func TestRuntimeIndexReplaceReusesCompiledStubs(t *testing.T) {
+	ri := NewRuntimeIndex()
+	keep := Mapping{ID: "keep", Request: Request{Method: "GET", URLPattern: "^/keep/\\d+$", URLMatch: "regex"}}
+	change := Mapping{ID: "change", Request: Request{Method: "GET", URLPattern: "^/change$", URLMatch: "regex"}}
+	if err := ri.Replace(func([]Mapping) ([]Mapping, error) { return []Mapping{change, keep}, nil }); err != nil {
+		t.Fatalf("Replace error = %v", err)
+	}
+	before := ri.snapshot().stubs
+
+	changed := change
+	changed.Request.URLPattern = "^/changed$"
+	if err := ri.Replace(func([]Mapping) ([]Mapping, error) { return []Mapping{keep, changed}, nil }); err != nil {
+		t.Fatalf("Replace error = %v", err)
+	}
+	after := ri.snapshot().stubs
+
+	if after[0].mapping.ID != "keep" || after[0].regex != before[1].regex || after[0].order != 1 {
+		t.Fatalf("keep stub = %+v, want the compiled regex reused at order 1", after[0])
+	}
+	if after[1].regex == before[0].regex || after[1].order != 2 {
+		t.Fatalf("changed stub = %+v, want it recompiled at order 2", after[1])
+	}
+	if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/changed"}); !ok {
+		t.Fatal("FindBestMatch(/changed) = no match")
+	}
+}
+
+// Test that Reset also forgets scenario states and responses positions.
+func TestRuntimeIndexResetClearsState(t *testing.T) {
+	ri := NewRuntimeIndex()
+	if err := ri.Add(responsesMapping(ResponseModeSequence, nil, 500, 200)); err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	renderStatuses(t, ri, 1)
+	ri.SetScenarioState("checkout", "paid")
+
+	ri.Reset()
+	if st := ri.scenarios.get("checkout"); st != ScenarioStarted {
+		t.Fatalf("scenario state after Reset = %q, want %q", st, ScenarioStarted)
+	}
+	if err := ri.Add(responsesMapping(ResponseModeSequence, nil, 500, 200)); err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	if got := renderStatuses(t, ri, 1); got[0] != 500 {
+		t.Fatalf("first status after Reset = %d, want 500", got[0])
+	}
+}
+
+// Test that lookups racing with Replace always see a complete snapshot:
+// the stub present in every published set must always match, and the admin
+// view must always agree with the index.
+func TestRuntimeIndexReplaceIsAtomic(t *testing.T) {
+	ri := NewRuntimeIndex()
+	build := func(n int) func([]Mapping) ([]Mapping, error) {
+		return func([]Mapping) ([]Mapping, error) {
+			list := []Mapping{{ID: "always", Request: Request{Method: "GET", URLPattern: "/always", URLMatch: "exact"}}}
+			for i := 0; i < n; i++ {
+				list = append(list, Mapping{ID: fmt.Sprintf("m%d", i), Request: Request{Method: "GET", URLPattern: fmt.Sprintf("/m/%d", i)}})
+			}
+			return list, nil
+		}
+	}
+	if err := ri.Replace(build(10)); err != nil {
+		t.Fatalf("Replace error = %v", err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/always"}); !ok {
+					t.Errorf("FindBestMatch(/always) missed during Replace")
+					return
+				}
+				snap := ri.snapshot()
+				if len(snap.mappings) != len(snap.stubs) {
+					t.Errorf("snapshot mappings=%d stubs=%d, want equal", len(snap.mappings), len(snap.stubs))
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		if err := ri.Replace(build(i % 20)); err != nil {
+			t.Fatalf("Replace error = %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
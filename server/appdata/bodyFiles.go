@@ -0,0 +1,80 @@
+package appdata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Relative response.bodyFile paths are resolved against the mocks root, so
+// mappings can reference payloads like "payloads/big-catalog.json".
+var (
+	mocksRootMu sync.RWMutex
+	mocksRoot   = "mocks"
+)
+
+// SetMocksRoot sets the directory relative bodyFile paths are resolved against.
+func SetMocksRoot(dir string) {
+	mocksRootMu.Lock()
+	defer mocksRootMu.Unlock()
+	mocksRoot = dir
+}
+
+// bodyFileRoot opens the mocks root for reading name. The bodyFile must be
+// a relative path that stays inside the root; reads through the returned
+// os.Root cannot escape it via symlinks either.
+func bodyFileRoot(name string) (*os.Root, string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return nil, "", fmt.Errorf("%q must be a relative path inside the mocks directory", name)
+	}
+	mocksRootMu.RLock()
+	dir := mocksRoot
+	mocksRootMu.RUnlock()
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, local, nil
+}
+
+// ReadBodyFile reads a response.bodyFile from below the mocks root.
+func ReadBodyFile(name string) ([]byte, error) {
+	root, local, err := bodyFileRoot(name)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.ReadFile(local)
+}
+
+// CheckBodyFile reports, as a *ValidationError, a mapping whose
+// response.bodyFile (or the bodyFile of any entry in responses) does not
+// exist or lies outside the mocks root.
+func CheckBodyFile(m Mapping) error {
+	if err := checkBodyFile(m.ID, "response", m.Response); err != nil {
+		return err
+	}
+	for i, resp := range m.Responses {
+		if err := checkBodyFile(m.ID, fmt.Sprintf("responses[%d]", i), resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkBodyFile(id, field string, resp Response) error {
+	if resp.BodyFile == "" {
+		return nil
+	}
+	root, local, err := bodyFileRoot(resp.BodyFile)
+	if err == nil {
+		defer root.Close()
+		_, err = root.Stat(local)
+	}
+	if err != nil {
+		return &ValidationError{Err: fmt.Errorf("mapping %q: %s.bodyFile: %w", id, field, err)}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package appdata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withMocksRoot points bodyFile resolution at a temporary directory holding
+// payloads/ok.json, with a secret file next to it outside the root.
+func withMocksRoot(t *testing.T) string {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "mocks")
+	if err := os.MkdirAll(filepath.Join(root, "payloads"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "payloads", "ok.json"), []byte(`{"ok":true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	SetMocksRoot(root)
+	t.Cleanup(func() { SetMocksRoot("mocks") })
+	return base
+}
+
+// Test that bodyFile paths are read only from inside the mocks root.
+func TestReadBodyFile(t *testing.T) {
+	base := withMocksRoot(t)
+	if err := os.Symlink(filepath.Join(base, "secret"), filepath.Join(base, "mocks", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := ReadBodyFile("payloads/ok.json"); err != nil || string(b) != `{"ok":true}` {
+		t.Fatalf("ReadBodyFile(payloads/ok.json) = %q, %v", b, err)
+	}
+	for _, name := range []string{
+		"../secret",
+		"payloads/../../secret",
+		filepath.Join(base, "secret"),
+		"/etc/passwd",
+		"link",
+		"missing.json",
+	} {
+		if b, err := ReadBodyFile(name); err == nil {
+			t.Errorf("ReadBodyFile(%q) = %q, want error", name, b)
+		}
+	}
+}
+
+// Test that CheckBodyFile reports missing, absolute and escaping paths as
+// validation errors naming the mapping and the response entry.
+func TestCheckBodyFile(t *testing.T) {
+	withMocksRoot(t)
+
+	tests := []struct {
+		name    string
+		m       Mapping
+		wantErr string
+	}{
+		{"inside root", Mapping{ID: "ok", Response: Response{BodyFile: "payloads/ok.json"}}, ""},
+		{"missing", Mapping{ID: "m", Response: Response{BodyFile: "payloads/missing.json"}}, `mapping "m": response.bodyFile`},
+		{"traversal", Mapping{ID: "t", Response: Response{BodyFile: "../secret"}}, "inside the mocks directory"},
+		{"absolute", Mapping{ID: "a", Response: Response{BodyFile: "/etc/passwd"}}, "inside the mocks directory"},
+		{"in responses", Mapping{ID: "r", Responses: []Response{{Status: 200}, {BodyFile: "../../root/.ssh/id_rsa"}}}, `mapping "r": responses[1].bodyFile`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBodyFile(tt.m)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckBodyFile error = %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckBodyFile error = %v, want validation error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test that admin writes cannot point a mapping at a file outside the root.
+func TestCreateMappingRejectsEscapingBodyFile(t *testing.T) {
+	withMocksRoot(t)
+	Global.Reset()
+	t.Cleanup(Global.Reset)
+
+	for _, name := range []string{"/etc/passwd", "../secret"} {
+		_, err := CreateMapping(Mapping{
+			ID:       "leak",
+			Request:  Request{Method: "GET", URLPattern: "/leak"},
+			Response: Response{BodyFile: name},
+		})
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !strings.Contains(err.Error(), `mapping "leak"`) {
+			t.Errorf("CreateMapping(bodyFile %q) error = %v, want validation error naming the mapping", name, err)
+		}
+	}
+	if _, ok := GetMapping("leak"); ok {
+		t.Fatal("mapping with escaping bodyFile was registered")
+	}
+}
@@ -1,6 +1,10 @@
 package appdata
 
 import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,31 +16,323 @@ type CallRecord struct {
 	Method      string              `json:"method"`
 	URL         string              `json:"url"`
 	Query       map[string][]string `json:"query,omitempty"`
+	PathParams  map[string]string   `json:"pathParams,omitempty"`
 	RequestBody any                 `json:"requestBody,omitempty"`
 	MappingID   string              `json:"mappingId,omitempty"`
 	Status      int                 `json:"status"`
+	// Fault is the transport fault injected instead of a response, if any.
+	Fault string `json:"fault,omitempty"`
+	// Chaos is set when chaos mode (see chaos.go) changed this call.
+	Chaos *ChaosInjection `json:"chaos,omitempty"`
+	// ProxiedTo is the upstream URL the call was forwarded to, with the
+	// upstream's status (0 if it could not be reached) and error.
+	ProxiedTo   string `json:"proxiedTo,omitempty"`
+	ProxyStatus int    `json:"proxyStatus,omitempty"`
+	ProxyError  string `json:"proxyError,omitempty"`
+
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// RequestHeaders and ResponseHeaders are what was received and sent,
+	// with the history's redactHeaders masked.
+	RequestHeaders  map[string][]string `json:"requestHeaders,omitempty"`
+	ResponseHeaders map[string][]string `json:"responseHeaders,omitempty"`
+	// ResponseBody is the body sent, parsed like RequestBody; a body that is
+	// not text is kept as ResponseBase64Body instead.
+	ResponseBody       any    `json:"responseBody,omitempty"`
+	ResponseBase64Body string `json:"responseBase64Body,omitempty"`
+	// DelayMs is the delay applied before responding, chaos latency
+	// included. DurationMs is the time from receiving the request until the
+	// response was ready to send; a dribbled body takes longer to arrive.
+	DelayMs    int     `json:"delayMs,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+
+	// BodyOmitted is set when the bodies alone exceeded the history's
+	// maxBodyBytes and were not kept.
+	BodyOmitted bool `json:"bodyOmitted,omitempty"`
 }
 
-var (
-	callHistoryMu sync.RWMutex
-	callHistory   []CallRecord
-)
+// incoming rebuilds the request as the matchers saw it.
+func (rec CallRecord) incoming() IncomingRequest {
+	return IncomingRequest{
+		Method:     rec.Method,
+		URL:        rec.URL,
+		Query:      rec.Query,
+		Headers:    rec.RequestHeaders,
+		Body:       rec.RequestBody,
+		RemoteAddr: rec.RemoteAddr,
+	}
+}
+
+// redactedValue replaces the values of redacted headers.
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactHeaders are masked in history unless configured otherwise.
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// HistoryConfig bounds the in-memory call history. When a limit is reached
+// the oldest calls are dropped first. Zero disables a limit.
+type HistoryConfig struct {
+	// MaxEntries caps the number of calls kept.
+	MaxEntries int `json:"maxEntries"`
+	// MaxBodyBytes caps the total size of the bodies kept. A call whose own
+	// bodies exceed it is kept without them (see CallRecord.BodyOmitted).
+	MaxBodyBytes int `json:"maxBodyBytes"`
+	// TTLMs drops calls older than this many milliseconds.
+	TTLMs int `json:"ttlMs,omitempty"`
+	// RedactHeaders names request and response headers (case-insensitive)
+	// whose values are masked before a call is kept.
+	RedactHeaders []string `json:"redactHeaders"`
+}
+
+// DefaultHistoryConfig bounds a long-running server's history while
+// leaving plenty of room for a test run.
+var DefaultHistoryConfig = HistoryConfig{
+	MaxEntries:    10000,
+	MaxBodyBytes:  64 << 20,
+	RedactHeaders: DefaultRedactHeaders,
+}
+
+func (c HistoryConfig) validate() error {
+	var errs []error
+	if c.MaxEntries < 0 {
+		errs = append(errs, errors.New("maxEntries must not be negative"))
+	}
+	if c.MaxBodyBytes < 0 {
+		errs = append(errs, errors.New("maxBodyBytes must not be negative"))
+	}
+	if c.TTLMs < 0 {
+		errs = append(errs, errors.New("ttlMs must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// HistoryDropped counts the calls evicted from history since startup, by
+// the limit that evicted them.
+type HistoryDropped struct {
+	MaxEntries   int `json:"maxEntries"`
+	MaxBodyBytes int `json:"maxBodyBytes"`
+	TTL          int `json:"ttl"`
+}
 
-// RecordCall appends a call record to the in-memory history.
+// HistoryStats describes the call history and its limits.
+type HistoryStats struct {
+	Config    HistoryConfig  `json:"config"`
+	Entries   int            `json:"entries"`
+	BodyBytes int            `json:"bodyBytes"`
+	Dropped   HistoryDropped `json:"dropped"`
+}
+
+type historyEntry struct {
+	rec  CallRecord
+	size int
+}
+
+// historyStore is a ring buffer of calls. buf grows on demand up to
+// config.MaxEntries; the oldest call is at head.
+type historyStore struct {
+	mu        sync.RWMutex
+	config    HistoryConfig
+	buf       []historyEntry
+	head, n   int
+	bodyBytes int
+	dropped   HistoryDropped
+}
+
+var history = historyStore{config: DefaultHistoryConfig}
+
+func (h *historyStore) at(i int) *historyEntry {
+	return &h.buf[(h.head+i)%len(h.buf)]
+}
+
+func (h *historyStore) push(e historyEntry) {
+	if h.n == len(h.buf) {
+		h.resize(max(2*h.n, 16))
+	}
+	*h.at(h.n) = e
+	h.n++
+	h.bodyBytes += e.size
+}
+
+func (h *historyStore) popOldest() {
+	e := h.at(0)
+	h.bodyBytes -= e.size
+	*e = historyEntry{} // let the bodies be collected
+	h.head = (h.head + 1) % len(h.buf)
+	h.n--
+}
+
+// resize moves the calls to a new buffer of the given size, capped by
+// MaxEntries but never below the number of calls kept.
+func (h *historyStore) resize(size int) {
+	if m := h.config.MaxEntries; m > 0 && size > m {
+		size = max(m, h.n)
+	}
+	buf := make([]historyEntry, size)
+	for i := 0; i < h.n; i++ {
+		buf[i] = *h.at(i)
+	}
+	h.buf, h.head = buf, 0
+}
+
+// evictExpired drops calls older than the TTL. Calls are recorded when they
+// finish, so a slow call can sit behind a newer one; it is then dropped
+// when the calls before it are, and skipped by reads meanwhile.
+func (h *historyStore) evictExpired(now time.Time) {
+	if h.config.TTLMs == 0 {
+		return
+	}
+	cutoff := h.cutoff(now)
+	for h.n > 0 && h.at(0).rec.Time.Before(cutoff) {
+		h.popOldest()
+		h.dropped.TTL++
+	}
+}
+
+func (h *historyStore) cutoff(now time.Time) time.Time {
+	if h.config.TTLMs == 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(h.config.TTLMs) * time.Millisecond)
+}
+
+// evict drops the oldest calls until there is room for n more calls with
+// size bytes of bodies.
+func (h *historyStore) evict(n, size int) {
+	c := h.config
+	for c.MaxEntries > 0 && h.n > 0 && h.n+n > c.MaxEntries {
+		h.popOldest()
+		h.dropped.MaxEntries++
+	}
+	for c.MaxBodyBytes > 0 && h.n > 0 && h.bodyBytes+size > c.MaxBodyBytes {
+		h.popOldest()
+		h.dropped.MaxBodyBytes++
+	}
+}
+
+// bodySize estimates the memory a call's bodies hold by their JSON size.
+func (rec CallRecord) bodySize() int {
+	return jsonSize(rec.RequestBody) + jsonSize(rec.ResponseBody) + len(rec.ResponseBase64Body)
+}
+
+func jsonSize(v any) int {
+	switch b := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(b)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(raw)
+}
+
+// redact masks the configured headers in copies of rec's header maps.
+func (rec *CallRecord) redact(names []string) {
+	rec.RequestHeaders = redactHeaders(rec.RequestHeaders, names)
+	rec.ResponseHeaders = redactHeaders(rec.ResponseHeaders, names)
+}
+
+func redactHeaders(headers map[string][]string, names []string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(headers))
+	for k, vs := range headers {
+		if slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, k) }) {
+			vs = slices.Repeat([]string{redactedValue}, len(vs))
+		}
+		out[k] = vs
+	}
+	return out
+}
+
+// RecordCall appends a call record to the in-memory history, evicting the
+// oldest calls beyond the configured limits.
 func RecordCall(rec CallRecord) {
-	callHistoryMu.Lock()
-	defer callHistoryMu.Unlock()
-	callHistory = append(callHistory, rec)
+	size := rec.bodySize()
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	rec.redact(history.config.RedactHeaders)
+	if m := history.config.MaxBodyBytes; m > 0 && size > m {
+		rec.RequestBody, rec.ResponseBody, rec.ResponseBase64Body = nil, nil, ""
+		rec.BodyOmitted = true
+		size = 0
+	}
+	history.evictExpired(time.Now())
+	history.evict(1, size)
+	history.push(historyEntry{rec: rec, size: size})
+}
 
+// ResetCallHistory forgets every recorded call. The limits and dropped
+// counts are kept.
+func ResetCallHistory() {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.buf, history.head, history.n, history.bodyBytes = nil, 0, 0, 0
+}
+
+// SetHistoryConfig validates and installs new history limits, evicting
+// calls beyond them right away.
+func SetHistoryConfig(c HistoryConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	c.RedactHeaders = slices.Clone(c.RedactHeaders)
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.config = c
+	history.evictExpired(time.Now())
+	history.evict(0, 0)
+	if c.MaxEntries > 0 && len(history.buf) > c.MaxEntries {
+		history.resize(c.MaxEntries)
+	}
+	return nil
+}
+
+// GetHistoryConfig returns a copy of the history limits.
+func GetHistoryConfig() HistoryConfig {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+	c := history.config
+	c.RedactHeaders = slices.Clone(c.RedactHeaders)
+	return c
+}
+
+// isRedacted reports whether header values named name are masked in history.
+func isRedacted(name string) bool {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+	return slices.ContainsFunc(history.config.RedactHeaders, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// GetHistoryStats reports the history size and the calls dropped so far.
+func GetHistoryStats() HistoryStats {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.evictExpired(time.Now())
+	config := history.config
+	config.RedactHeaders = slices.Clone(config.RedactHeaders)
+	return HistoryStats{
+		Config:    config,
+		Entries:   history.n,
+		BodyBytes: history.bodyBytes,
+		Dropped:   history.dropped,
+	}
 }
 
 // GetCallHistory returns a snapshot copy of the current call history.
 // This avoids data races if the caller iterates over the slice.
 func GetCallHistory() []CallRecord {
-	callHistoryMu.RLock()
-	defer callHistoryMu.RUnlock()
-
-	out := make([]CallRecord, len(callHistory))
-	copy(out, callHistory)
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+	cutoff := history.cutoff(time.Now())
+	out := make([]CallRecord, 0, history.n)
+	for i := 0; i < history.n; i++ {
+		if rec := history.at(i).rec; !rec.Time.Before(cutoff) {
+			out = append(out, rec)
+		}
+	}
 	return out
 }
@@ -0,0 +1,180 @@
+package appdata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withHistoryConfig installs c on an empty history for the rest of the test.
+func withHistoryConfig(t *testing.T, c HistoryConfig) {
+	t.Helper()
+	ResetCallHistory()
+	if err := SetHistoryConfig(c); err != nil {
+		t.Fatalf("SetHistoryConfig error = %v", err)
+	}
+	t.Cleanup(func() {
+		ResetCallHistory()
+		_ = SetHistoryConfig(DefaultHistoryConfig)
+	})
+}
+
+func historyURLs() string {
+	var urls []string
+	for _, rec := range GetCallHistory() {
+		urls = append(urls, rec.URL)
+	}
+	return strings.Join(urls, " ")
+}
+
+// Test that the oldest calls are dropped once maxEntries is reached, across
+// several laps of the ring buffer.
+func TestHistoryMaxEntries(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{MaxEntries: 3})
+	before := GetHistoryStats().Dropped.MaxEntries
+
+	for _, u := range []string{"/1", "/2", "/3", "/4", "/5", "/6", "/7"} {
+		RecordCall(CallRecord{Method: "GET", URL: u, Time: time.Now()})
+	}
+	if got, want := historyURLs(), "/5 /6 /7"; got != want {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+	stats := GetHistoryStats()
+	if stats.Entries != 3 || stats.Dropped.MaxEntries-before != 4 {
+		t.Fatalf("stats = %+v, want 3 entries and 4 dropped", stats)
+	}
+
+	// Lowering the limit evicts right away.
+	if err := SetHistoryConfig(HistoryConfig{MaxEntries: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := historyURLs(), "/7"; got != want {
+		t.Fatalf("history after lowering = %q, want %q", got, want)
+	}
+}
+
+// Test that bodies are accounted for and evict the oldest calls, and that a
+// call whose body alone is too large is kept without it.
+func TestHistoryMaxBodyBytes(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{MaxBodyBytes: 12})
+	before := GetHistoryStats().Dropped.MaxBodyBytes
+
+	RecordCall(CallRecord{URL: "/a", RequestBody: "12345", Time: time.Now()})
+	RecordCall(CallRecord{URL: "/b", RequestBody: "12345", Time: time.Now()})
+	RecordCall(CallRecord{URL: "/c", RequestBody: map[string]any{"k": 1}, Time: time.Now()}) // {"k":1}
+	if got, want := historyURLs(), "/b /c"; got != want {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+	if s := GetHistoryStats(); s.BodyBytes != 12 || s.Dropped.MaxBodyBytes-before != 1 {
+		t.Fatalf("stats = %+v, want 12 body bytes and 1 dropped", s)
+	}
+
+	RecordCall(CallRecord{URL: "/big", RequestBody: strings.Repeat("x", 13), Time: time.Now()})
+	calls := GetCallHistory()
+	last := calls[len(calls)-1]
+	if last.URL != "/big" || last.RequestBody != nil || !last.BodyOmitted {
+		t.Fatalf("oversized call = %+v, want it kept without body", last)
+	}
+}
+
+// Test that calls older than the TTL are skipped and then dropped.
+func TestHistoryTTL(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{TTLMs: 60000})
+	before := GetHistoryStats().Dropped.TTL
+
+	RecordCall(CallRecord{URL: "/old", Time: time.Now().Add(-2 * time.Minute)})
+	RecordCall(CallRecord{URL: "/new", Time: time.Now()})
+	if got, want := historyURLs(), "/new"; got != want {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+	if s := GetHistoryStats(); s.Entries != 1 || s.Dropped.TTL-before != 1 {
+		t.Fatalf("stats = %+v, want 1 entry and 1 dropped", s)
+	}
+}
+
+// Test paging by offset, limit and since.
+func TestQueryCallHistory(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{MaxEntries: 4})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, u := range []string{"/0", "/1", "/2", "/3", "/4", "/5"} {
+		RecordCall(CallRecord{URL: u, Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name      string
+		q         HistoryQuery
+		want      string
+		wantTotal int
+	}{
+		{"all", HistoryQuery{}, "/2 /3 /4 /5", 4},
+		{"offset", HistoryQuery{Offset: 1}, "/3 /4 /5", 4},
+		{"offset and limit", HistoryQuery{Offset: 1, Limit: 2}, "/3 /4", 4},
+		{"past the end", HistoryQuery{Offset: 9}, "", 4},
+		{"since", HistoryQuery{Since: start.Add(4 * time.Minute)}, "/4 /5", 2},
+		{"since with limit", HistoryQuery{Since: start.Add(3 * time.Minute), Limit: 1}, "/3", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, total, err := QueryCallHistory(tt.q)
+			if err != nil {
+				t.Fatalf("QueryCallHistory error = %v", err)
+			}
+			var urls []string
+			for _, c := range calls {
+				urls = append(urls, c.URL)
+			}
+			if got := strings.Join(urls, " "); got != tt.want || total != tt.wantTotal {
+				t.Fatalf("QueryCallHistory = %q (total %d), want %q (total %d)", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSetHistoryConfigInvalid(t *testing.T) {
+	bad := []HistoryConfig{{MaxEntries: -1}, {MaxBodyBytes: -1}, {TTLMs: -1}}
+	for _, c := range bad {
+		if err := SetHistoryConfig(c); err == nil {
+			t.Errorf("SetHistoryConfig(%+v) succeeded, want error", c)
+		}
+	}
+	if got := GetHistoryConfig(); !reflect.DeepEqual(got, DefaultHistoryConfig) {
+		t.Fatalf("config = %+v after rejected updates, want default", got)
+	}
+}
+
+// Test that configured headers are masked case-insensitively in copies of
+// the recorded maps.
+func TestRecordCallRedactsHeaders(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{RedactHeaders: []string{"authorization", "X-Api-Key"}})
+
+	req := map[string][]string{"Authorization": {"a", "b"}, "Accept": {"*/*"}}
+	RecordCall(CallRecord{
+		URL:             "/secure",
+		RequestHeaders:  req,
+		ResponseHeaders: map[string][]string{"x-api-key": {"k"}, "Content-Type": {"text/plain"}},
+	})
+
+	rec := GetCallHistory()[0]
+	want := map[string][]string{"Authorization": {"[REDACTED]", "[REDACTED]"}, "Accept": {"*/*"}}
+	if !reflect.DeepEqual(rec.RequestHeaders, want) {
+		t.Errorf("RequestHeaders = %v, want %v", rec.RequestHeaders, want)
+	}
+	if rec.ResponseHeaders["x-api-key"][0] != "[REDACTED]" || rec.ResponseHeaders["Content-Type"][0] != "text/plain" {
+		t.Errorf("ResponseHeaders = %v", rec.ResponseHeaders)
+	}
+	if req["Authorization"][0] != "a" {
+		t.Errorf("caller's headers were modified: %v", req)
+	}
+}
+
+// Test that the stats hand out a copy of the redaction list.
+func TestHistoryStatsCopiesRedactHeaders(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{RedactHeaders: []string{"Authorization"}})
+
+	GetHistoryStats().Config.RedactHeaders[0] = "X-Other"
+	if got := GetHistoryConfig().RedactHeaders; got[0] != "Authorization" {
+		t.Fatalf("RedactHeaders = %v, want the stats copy left unshared", got)
+	}
+}
@@ -0,0 +1,180 @@
+package appdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ChaosProfile switches the whole server into a misbehaving mode. Each rule
+// targets mappings by Metadata.Tags; the first rule that matches a mapping
+// decides what happens to the request. For example
+//
+//	{"enabled": true, "seed": 7, "rules": [
+//	  {"tags": ["payments"], "errorRate": 0.05, "errorStatus": 503,
+//	   "latencyRate": 0.10, "latencyMs": 2000}]}
+//
+// fails 5% of calls to mappings tagged "payments" with a 503 and slows down
+// 10% of them by two seconds. With a seed, the same sequence of requests
+// gets the same injections on every run.
+type ChaosProfile struct {
+	Enabled bool        `json:"enabled"`
+	Seed    *int64      `json:"seed,omitempty"`
+	Rules   []ChaosRule `json:"rules"`
+}
+
+// ChaosRule is one line of a ChaosProfile. Rates are probabilities in [0, 1].
+type ChaosRule struct {
+	// Tags selects mappings carrying any of these tags; empty matches every mapping.
+	Tags []string `json:"tags,omitempty"`
+
+	// ErrorRate is the chance of replacing the response with ErrorStatus
+	// (default 503), or with Fault when one is set.
+	ErrorRate   float64 `json:"errorRate,omitempty"`
+	ErrorStatus int     `json:"errorStatus,omitempty"`
+	Fault       string  `json:"fault,omitempty"`
+
+	// LatencyRate is the chance of adding LatencyMs before responding.
+	LatencyRate float64 `json:"latencyRate,omitempty"`
+	LatencyMs   int     `json:"latencyMs,omitempty"`
+}
+
+// ChaosInjection is what chaos mode did to one request; it is recorded in
+// call history.
+type ChaosInjection struct {
+	Rule    int    `json:"rule"` // index into ChaosProfile.Rules
+	Status  int    `json:"status,omitempty"`
+	Fault   string `json:"fault,omitempty"`
+	DelayMs int    `json:"delayMs,omitempty"`
+}
+
+// Delay is the latency chaos adds to the response.
+func (c *ChaosInjection) Delay() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(c.DelayMs) * time.Millisecond
+}
+
+func (p ChaosProfile) validate() error {
+	var errs []error
+	for i, r := range p.Rules {
+		if r.ErrorRate < 0 || r.ErrorRate > 1 {
+			errs = append(errs, fmt.Errorf("rules[%d].errorRate must be between 0 and 1", i))
+		}
+		if r.LatencyRate < 0 || r.LatencyRate > 1 {
+			errs = append(errs, fmt.Errorf("rules[%d].latencyRate must be between 0 and 1", i))
+		}
+		if r.ErrorStatus != 0 && (r.ErrorStatus < 100 || r.ErrorStatus > 599) {
+			errs = append(errs, fmt.Errorf("rules[%d].errorStatus %d is not an HTTP status", i, r.ErrorStatus))
+		}
+		if r.LatencyMs < 0 {
+			errs = append(errs, fmt.Errorf("rules[%d].latencyMs must not be negative", i))
+		}
+		if err := validateFault(r.Fault); err != nil {
+			errs = append(errs, fmt.Errorf("rules[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r ChaosRule) matches(tags []string) bool {
+	if len(r.Tags) == 0 {
+		return true
+	}
+	for _, t := range r.Tags {
+		if slices.Contains(tags, t) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	chaosMu      sync.Mutex
+	chaosProfile ChaosProfile
+	chaosRand    *rand.Rand
+)
+
+// SetChaosProfile validates and installs p, restarting its random sequence.
+func SetChaosProfile(p ChaosProfile) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	seed := time.Now().UnixNano()
+	if p.Seed != nil {
+		seed = *p.Seed
+	}
+
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	chaosProfile = p
+	chaosRand = rand.New(rand.NewSource(seed))
+	return nil
+}
+
+// GetChaosProfile returns the installed chaos profile.
+func GetChaosProfile() ChaosProfile {
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	return chaosProfile
+}
+
+// LoadChaosFile installs the chaos profile stored as JSON at path.
+func LoadChaosFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var p ChaosProfile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := SetChaosProfile(p); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ApplyChaos decides whether chaos mode hits a request served by m. It
+// returns nil when chaos is off, no rule matches, or the dice spared it.
+func ApplyChaos(m Mapping) *ChaosInjection {
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	if !chaosProfile.Enabled {
+		return nil
+	}
+	for i, r := range chaosProfile.Rules {
+		if !r.matches(m.Metadata.Tags) {
+			continue
+		}
+		// Always draw both numbers so the sequence only depends on the
+		// number of matching requests, which keeps seeded replays stable.
+		errRoll, latencyRoll := chaosRand.Float64(), chaosRand.Float64()
+
+		inj := ChaosInjection{Rule: i}
+		if errRoll < r.ErrorRate {
+			if r.Fault != "" {
+				inj.Fault = r.Fault
+			} else {
+				inj.Status = r.ErrorStatus
+				if inj.Status == 0 {
+					inj.Status = 503
+				}
+			}
+		}
+		if latencyRoll < r.LatencyRate {
+			inj.DelayMs = r.LatencyMs
+		}
+		if inj.Status == 0 && inj.Fault == "" && inj.DelayMs == 0 {
+			return nil
+		}
+		return &inj
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package appdata
+
+import "testing"
+
+func withChaos(t *testing.T, p ChaosProfile) {
+	t.Helper()
+	if err := SetChaosProfile(p); err != nil {
+		t.Fatalf("SetChaosProfile error = %v", err)
+	}
+	t.Cleanup(func() { _ = SetChaosProfile(ChaosProfile{}) })
+}
+
+// Test that a seeded profile injects the same failures on replay, only for
+// mappings carrying the rule's tags, at roughly the configured rates.
+func TestApplyChaos(t *testing.T) {
+	seed := int64(7)
+	profile := ChaosProfile{
+		Enabled: true,
+		Seed:    &seed,
+		Rules: []ChaosRule{{
+			Tags:        []string{"payments"},
+			ErrorRate:   0.05,
+			ErrorStatus: 503,
+			LatencyRate: 0.10,
+			LatencyMs:   2000,
+		}},
+	}
+	payments := Mapping{ID: "pay", Metadata: Metadata{Tags: []string{"api", "payments"}}}
+	other := Mapping{ID: "users", Metadata: Metadata{Tags: []string{"users"}}}
+
+	run := func() (errors, slow int, trace []ChaosInjection) {
+		withChaos(t, profile)
+		for i := 0; i < 2000; i++ {
+			if inj := ApplyChaos(other); inj != nil {
+				t.Fatalf("untagged mapping got chaos %+v", *inj)
+			}
+			inj := ApplyChaos(payments)
+			if inj == nil {
+				continue
+			}
+			trace = append(trace, *inj)
+			if inj.Status == 503 {
+				errors++
+			}
+			if inj.DelayMs == 2000 {
+				slow++
+			}
+		}
+		return errors, slow, trace
+	}
+
+	errors, slow, first := run()
+	if errors < 60 || errors > 140 {
+		t.Errorf("503 injected %d/2000 times, want about 100", errors)
+	}
+	if slow < 140 || slow > 260 {
+		t.Errorf("latency injected %d/2000 times, want about 200", slow)
+	}
+
+	_, _, second := run()
+	if len(first) != len(second) {
+		t.Fatalf("replay injected %d times, first run %d", len(second), len(first))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("replay differs at %d: %+v vs %+v", i, second[i], first[i])
+		}
+	}
+}
+
+func TestApplyChaosDisabled(t *testing.T) {
+	withChaos(t, ChaosProfile{Enabled: false, Rules: []ChaosRule{{ErrorRate: 1}}})
+	if inj := ApplyChaos(Mapping{}); inj != nil {
+		t.Fatalf("disabled profile injected %+v", *inj)
+	}
+}
+
+func TestSetChaosProfileValidation(t *testing.T) {
+	bad := []ChaosRule{
+		{ErrorRate: 1.5},
+		{LatencyRate: -0.1},
+		{ErrorStatus: 42},
+		{LatencyMs: -1},
+		{Fault: "SLOW_LORIS"},
+	}
+	for _, r := range bad {
+		if err := SetChaosProfile(ChaosProfile{Enabled: true, Rules: []ChaosRule{r}}); err == nil {
+			t.Errorf("SetChaosProfile(%+v) succeeded, want error", r)
+		}
+	}
+}
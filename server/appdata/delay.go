@@ -0,0 +1,96 @@
+package appdata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Delay distribution types for Response.DelayDistribution. All durations
+// are in milliseconds.
+//
+//   - uniform: a delay drawn evenly from [lower, upper].
+//   - lognormal: a long-tailed delay around median; sigma widens the tail.
+//   - chunkedDribble: send headers at once, then the body in numberOfChunks
+//     pieces spread over totalDuration.
+const (
+	DelayUniform        = "uniform"
+	DelayLognormal      = "lognormal"
+	DelayChunkedDribble = "chunkedDribble"
+)
+
+// Lognormal delays have no upper bound, so sigma is capped at load time and
+// every sample is clamped to lognormalMaxDelayMs.
+const (
+	lognormalMaxSigma   = 5.0
+	lognormalMaxDelayMs = 5 * 60 * 1000
+)
+
+// DelayDistribution is a randomized (or dribbled) delay, e.g.
+// {"type": "lognormal", "median": 80, "sigma": 0.4}.
+type DelayDistribution struct {
+	Type string `json:"type"`
+
+	Lower int `json:"lower,omitempty"`
+	Upper int `json:"upper,omitempty"`
+
+	Median int     `json:"median,omitempty"`
+	Sigma  float64 `json:"sigma,omitempty"`
+
+	NumberOfChunks int `json:"numberOfChunks,omitempty"`
+	TotalDuration  int `json:"totalDuration,omitempty"`
+}
+
+func validateDelay(resp Response) error {
+	if resp.FixedDelayMs < 0 {
+		return errors.New("fixedDelayMs must not be negative")
+	}
+	d := resp.DelayDistribution
+	if d == nil {
+		return nil
+	}
+	switch d.Type {
+	case DelayUniform:
+		if d.Lower < 0 || d.Upper < d.Lower {
+			return fmt.Errorf("delayDistribution: uniform needs 0 <= lower <= upper, got %d..%d", d.Lower, d.Upper)
+		}
+	case DelayLognormal:
+		if d.Median <= 0 || d.Median > lognormalMaxDelayMs || d.Sigma < 0 || d.Sigma > lognormalMaxSigma {
+			return fmt.Errorf("delayDistribution: lognormal needs 0 < median <= %d and 0 <= sigma <= %g", lognormalMaxDelayMs, lognormalMaxSigma)
+		}
+	case DelayChunkedDribble:
+		if d.NumberOfChunks < 1 || d.TotalDuration < 0 {
+			return errors.New("delayDistribution: chunkedDribble needs numberOfChunks >= 1 and totalDuration >= 0")
+		}
+	default:
+		return fmt.Errorf("delayDistribution: unknown type %q", d.Type)
+	}
+	return nil
+}
+
+// Delay returns how long to wait before sending the response: fixedDelayMs
+// plus a fresh sample from a uniform or lognormal delayDistribution. Lognormal
+// samples are clamped to lognormalMaxDelayMs.
+func (r Response) Delay() time.Duration {
+	ms := float64(r.FixedDelayMs)
+	if d := r.DelayDistribution; d != nil {
+		switch d.Type {
+		case DelayUniform:
+			ms += float64(d.Lower) + rand.Float64()*float64(d.Upper-d.Lower)
+		case DelayLognormal:
+			ms += math.Min(float64(d.Median)*math.Exp(d.Sigma*rand.NormFloat64()), lognormalMaxDelayMs)
+		}
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Dribble reports whether the body should be sent in chunks over time.
+func (r Response) Dribble() (chunks int, total time.Duration, ok bool) {
+	d := r.DelayDistribution
+	if d == nil || d.Type != DelayChunkedDribble {
+		return 0, 0, false
+	}
+	return d.NumberOfChunks, time.Duration(d.TotalDuration) * time.Millisecond, true
+}
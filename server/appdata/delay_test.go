@@ -0,0 +1,69 @@
+package appdata
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that sampled delays stay within the configured distribution.
+func TestResponseDelay(t *testing.T) {
+	uniform := Response{FixedDelayMs: 10, DelayDistribution: &DelayDistribution{Type: DelayUniform, Lower: 20, Upper: 40}}
+	for i := 0; i < 200; i++ {
+		if d := uniform.Delay(); d < 30*time.Millisecond || d > 50*time.Millisecond {
+			t.Fatalf("uniform Delay() = %v, want 30ms..50ms", d)
+		}
+	}
+
+	lognormal := Response{DelayDistribution: &DelayDistribution{Type: DelayLognormal, Median: 100, Sigma: 0.5}}
+	below := 0
+	for i := 0; i < 1000; i++ {
+		d := lognormal.Delay()
+		if d <= 0 {
+			t.Fatalf("lognormal Delay() = %v, want > 0", d)
+		}
+		if d < 100*time.Millisecond {
+			below++
+		}
+	}
+	if below < 400 || below > 600 {
+		t.Fatalf("%d/1000 lognormal samples below the median, want about half", below)
+	}
+
+	wide := Response{DelayDistribution: &DelayDistribution{Type: DelayLognormal, Median: lognormalMaxDelayMs, Sigma: lognormalMaxSigma}}
+	for i := 0; i < 200; i++ {
+		if d := wide.Delay(); d > lognormalMaxDelayMs*time.Millisecond {
+			t.Fatalf("wide lognormal Delay() = %v, want at most %dms", d, lognormalMaxDelayMs)
+		}
+	}
+
+	dribble := Response{FixedDelayMs: 5, DelayDistribution: &DelayDistribution{Type: DelayChunkedDribble, NumberOfChunks: 4, TotalDuration: 200}}
+	if d := dribble.Delay(); d != 5*time.Millisecond {
+		t.Fatalf("dribble Delay() = %v, want only the fixed 5ms", d)
+	}
+	if n, total, ok := dribble.Dribble(); !ok || n != 4 || total != 200*time.Millisecond {
+		t.Fatalf("Dribble() = %d, %v, %v", n, total, ok)
+	}
+}
+
+func TestDelayValidation(t *testing.T) {
+	bad := []*DelayDistribution{
+		{Type: "exponential"},
+		{Type: DelayUniform, Lower: 50, Upper: 10},
+		{Type: DelayUniform, Lower: -1, Upper: 10},
+		{Type: DelayLognormal, Median: 0, Sigma: 0.1},
+		{Type: DelayLognormal, Median: 10, Sigma: -1},
+		{Type: DelayLognormal, Median: 10, Sigma: 500},
+		{Type: DelayLognormal, Median: lognormalMaxDelayMs + 1, Sigma: 0.1},
+		{Type: DelayChunkedDribble, NumberOfChunks: 0, TotalDuration: 100},
+	}
+	for _, d := range bad {
+		m := Mapping{
+			ID:       "delay",
+			Request:  Request{Method: "GET", URLPattern: "/slow"},
+			Response: Response{DelayDistribution: d},
+		}
+		if err := NewRuntimeIndex().Add(m); err == nil {
+			t.Errorf("Add(%+v) succeeded, want error", *d)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package appdata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Body field paths use dot-notation extended with array segments:
+//
+//	customer.email     object keys
+//	items[0].sku       array index (negative counts from the end: items[-1])
+//	items[*].type      any element must satisfy the matcher
+//	items[all].type    every element must satisfy the matcher
+//	meta.a\.b          backslash escapes a literal '.', '[' or '\' in a key
+type segmentKind int
+
+const (
+	segKey segmentKind = iota
+	segIndex
+	segAny
+	segAll
+)
+
+type pathSegment struct {
+	kind  segmentKind
+	key   string
+	index int
+}
+
+func parseDotPath(path string) ([]pathSegment, error) {
+	var (
+		segs    []pathSegment
+		key     strings.Builder
+		haveKey bool
+		dangle  bool // a '.' was seen and no segment followed yet
+	)
+	flushKey := func() {
+		if haveKey {
+			segs = append(segs, pathSegment{kind: segKey, key: key.String()})
+			key.Reset()
+			haveKey = false
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(path) {
+				return nil, fmt.Errorf("path %q: dangling escape", path)
+			}
+			i++
+			key.WriteByte(path[i])
+			haveKey, dangle = true, false
+		case '.':
+			if !haveKey && (len(segs) == 0 || segs[len(segs)-1].kind == segKey) {
+				return nil, fmt.Errorf("path %q: empty segment", path)
+			}
+			flushKey()
+			dangle = true
+		case '[':
+			flushKey()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: unclosed '['", path)
+			}
+			seg, err := parseBracket(path[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("path %q: %w", path, err)
+			}
+			segs = append(segs, seg)
+			dangle = false
+			i += end
+		default:
+			key.WriteByte(c)
+			haveKey, dangle = true, false
+		}
+	}
+	flushKey()
+
+	if dangle {
+		return nil, fmt.Errorf("path %q: empty segment", path)
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("path %q: empty", path)
+	}
+	return segs, nil
+}
+
+func parseBracket(inner string) (pathSegment, error) {
+	switch strings.TrimSpace(inner) {
+	case "*", "any":
+		return pathSegment{kind: segAny}, nil
+	case "all":
+		return pathSegment{kind: segAll}, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(inner))
+	if err != nil {
+		return pathSegment{}, fmt.Errorf("invalid array segment [%s]", inner)
+	}
+	return pathSegment{kind: segIndex, index: n}, nil
+}
+
+// matchPath walks body along segs and applies m to the value(s) found.
+// Missing values are reported to m as not present.
+func matchPath(cur any, segs []pathSegment, m valueMatcher) bool {
+	if len(segs) == 0 {
+		return m.match(cur, true)
+	}
+	seg, rest := segs[0], segs[1:]
+
+	switch seg.kind {
+	case segKey:
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return m.match(nil, false)
+		}
+		next, ok := obj[seg.key]
+		if !ok {
+			return m.match(nil, false)
+		}
+		return matchPath(next, rest, m)
+	case segIndex:
+		next, ok := indexArray(cur, seg.index)
+		if !ok {
+			return m.match(nil, false)
+		}
+		return matchPath(next, rest, m)
+	case segAny:
+		arr, ok := cur.([]any)
+		if !ok {
+			return m.match(nil, false)
+		}
+		for _, el := range arr {
+			if matchPath(el, rest, m) {
+				return true
+			}
+		}
+		return false
+	case segAll:
+		arr, ok := cur.([]any)
+		if !ok || len(arr) == 0 {
+			return false
+		}
+		for _, el := range arr {
+			if !matchPath(el, rest, m) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func indexArray(cur any, i int) (any, bool) {
+	arr, ok := cur.([]any)
+	if !ok {
+		return nil, false
+	}
+	if i < 0 {
+		i += len(arr)
+	}
+	if i < 0 || i >= len(arr) {
+		return nil, false
+	}
+	return arr[i], true
+}
+
+// LookupBody returns the value at path in a decoded JSON body. Paths starting
+// with '$' are JSONPath (the first result wins); others are dot paths.
+func LookupBody(body any, path string) (any, bool, error) {
+	bp, err := compileBodyPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	v, ok := bp.lookup(body)
+	return v, ok, nil
+}
+
+// bodyPath is a LookupBody path parsed once, for callers that look it up
+// on every request.
+type bodyPath struct {
+	jp   *jsonPath
+	segs []pathSegment
+}
+
+func compileBodyPath(path string) (*bodyPath, error) {
+	if strings.HasPrefix(path, "$") {
+		jp, err := compileJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		return &bodyPath{jp: jp}, nil
+	}
+	segs, err := parseDotPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return &bodyPath{segs: segs}, nil
+}
+
+// lookup resolves the path to a single value. Dot path wildcards resolve to
+// the first element, like the first JSONPath result.
+func (bp *bodyPath) lookup(body any) (any, bool) {
+	if bp.jp != nil {
+		if nodes := bp.jp.eval(body); len(nodes) > 0 {
+			return nodes[0], true
+		}
+		return nil, false
+	}
+	cur := body
+	for _, seg := range bp.segs {
+		var ok bool
+		switch seg.kind {
+		case segKey:
+			var obj map[string]any
+			if obj, ok = cur.(map[string]any); ok {
+				cur, ok = obj[seg.key]
+			}
+		case segIndex:
+			cur, ok = indexArray(cur, seg.index)
+		case segAny, segAll:
+			cur, ok = indexArray(cur, 0)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,93 @@
+package appdata
+
+import "testing"
+
+// Test indexed, wildcard and escaped body paths against an order payload.
+func TestBodyPathMatching(t *testing.T) {
+	body := map[string]any{
+		"items": []any{
+			map[string]any{"sku": "A-1", "type": "standard"},
+			map[string]any{"sku": "B-2", "type": "gift"},
+		},
+		"meta": map[string]any{"a.b": "dotted"},
+	}
+
+	cases := []struct {
+		path     string
+		expected any
+		want     bool
+	}{
+		{"items[0].sku", "A-1", true},
+		{"items[1].sku", "A-1", false},
+		{"items[-1].sku", "B-2", true},
+		{"items[5].sku", map[string]any{"absent": true}, true},
+		{"items[*].type", "gift", true},
+		{"items[*].type", "digital", false},
+		{"items[all].type", map[string]any{"oneOf": []any{"standard", "gift"}}, true},
+		{"items[all].type", "gift", false},
+		{`meta.a\.b`, "dotted", true},
+		{"meta.a.b", map[string]any{"absent": true}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			segs, err := parseDotPath(tc.path)
+			if err != nil {
+				t.Fatalf("parseDotPath(%q) error = %v", tc.path, err)
+			}
+			m, err := compileValueMatcher(tc.expected)
+			if err != nil {
+				t.Fatalf("compileValueMatcher(%v) error = %v", tc.expected, err)
+			}
+			if got := matchPath(body, segs, m); got != tc.want {
+				t.Fatalf("matchPath(%q, %v) = %v, want %v", tc.path, tc.expected, got, tc.want)
+			}
+		})
+	}
+}
+
+// Test that malformed paths are rejected.
+func TestParseDotPathErrors(t *testing.T) {
+	for _, p := range []string{"", "a..b", "a.", "items[", "items[x]", `a\`} {
+		if _, err := parseDotPath(p); err == nil {
+			t.Errorf("parseDotPath(%q) error = nil, want error", p)
+		}
+	}
+}
+
+// Test that LookupBody resolves dot paths and JSONPath to a single value.
+func TestLookupBody(t *testing.T) {
+	body := map[string]any{
+		"items": []any{
+			map[string]any{"sku": "A-1"},
+			map[string]any{"sku": "B-2"},
+		},
+		"meta": map[string]any{"a.b": "dotted"},
+	}
+
+	cases := []struct {
+		path   string
+		want   any
+		wantOK bool
+	}{
+		{"items[1].sku", "B-2", true},
+		{"items[-1].sku", "B-2", true},
+		{"items[*].sku", "A-1", true},
+		{`meta.a\.b`, "dotted", true},
+		{"$.items[1].sku", "B-2", true},
+		{"$..sku", "A-1", true},
+		{"items[2].sku", nil, false},
+		{"meta.a.b", nil, false},
+		{"items.sku", nil, false},
+		{"$.missing", nil, false},
+	}
+	for _, tc := range cases {
+		got, ok, err := LookupBody(body, tc.path)
+		if err != nil || ok != tc.wantOK || got != tc.want {
+			t.Errorf("LookupBody(%q) = %v, %v, %v, want %v, %v", tc.path, got, ok, err, tc.want, tc.wantOK)
+		}
+	}
+	if _, _, err := LookupBody(body, "items["); err == nil {
+		t.Error("LookupBody(items[) error = nil, want error")
+	}
+}
@@ -0,0 +1,26 @@
+package appdata
+
+import "fmt"
+
+// Transport-level faults for Response.Fault. Instead of a normal response
+// the server misbehaves on the connection:
+//
+//   - CONNECTION_RESET_BY_PEER: close the connection with a TCP reset.
+//   - EMPTY_RESPONSE: close the connection without sending anything.
+//   - MALFORMED_RESPONSE_CHUNK: send the status line and headers, then an
+//     invalid chunked body, then close.
+//   - RANDOM_DATA_THEN_CLOSE: send random bytes (no HTTP framing), then close.
+const (
+	FaultConnectionResetByPeer = "CONNECTION_RESET_BY_PEER"
+	FaultEmptyResponse         = "EMPTY_RESPONSE"
+	FaultMalformedChunk        = "MALFORMED_RESPONSE_CHUNK"
+	FaultRandomDataThenClose   = "RANDOM_DATA_THEN_CLOSE"
+)
+
+func validateFault(fault string) error {
+	switch fault {
+	case "", FaultConnectionResetByPeer, FaultEmptyResponse, FaultMalformedChunk, FaultRandomDataThenClose:
+		return nil
+	}
+	return fmt.Errorf("unknown fault %q", fault)
+}
@@ -0,0 +1,149 @@
+package appdata
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HistoryQuery filters, sorts and pages the call history. Zero fields do
+// not filter; calls must pass every filter that is set.
+type HistoryQuery struct {
+	Method    string
+	URLPrefix string
+	URLRegex  string
+	MappingID string
+	// StatusMin and StatusMax bound the recorded status, inclusive; nil does
+	// not bound. Status 0 selects cancelled calls and connection faults.
+	StatusMin *int
+	StatusMax *int
+	// Unmatched keeps only calls no mapping matched.
+	Unmatched bool
+	// Tags keeps calls whose mapping currently carries any of these tags.
+	Tags []string
+	// Since and Until keep calls at or after Since and before Until.
+	Since time.Time
+	Until time.Time
+	// Body holds request body predicates keyed by dot path or JSONPath, with
+	// the same values and operators as a mapping's request body.
+	Body map[string]any
+
+	// Sort is a field to order by: time (the default), status, duration,
+	// method, url or mappingId; a leading "-" reverses it.
+	Sort   string
+	Offset int
+	// Limit caps the page size; zero means no limit.
+	Limit int
+}
+
+var historySorts = map[string]func(a, b CallRecord) int{
+	"time":      func(a, b CallRecord) int { return a.Time.Compare(b.Time) },
+	"status":    func(a, b CallRecord) int { return cmp.Compare(a.Status, b.Status) },
+	"duration":  func(a, b CallRecord) int { return cmp.Compare(a.DurationMs, b.DurationMs) },
+	"method":    func(a, b CallRecord) int { return cmp.Compare(a.Method, b.Method) },
+	"url":       func(a, b CallRecord) int { return cmp.Compare(a.URL, b.URL) },
+	"mappingId": func(a, b CallRecord) int { return cmp.Compare(a.MappingID, b.MappingID) },
+}
+
+// historyFilter is a compiled HistoryQuery.
+type historyFilter struct {
+	q       HistoryQuery
+	urlRe   *regexp.Regexp
+	body    *compiledStub
+	tags    map[string][]string // mapping ID -> tags, when filtering by tag
+	compare func(a, b CallRecord) int
+}
+
+func (q HistoryQuery) compile() (*historyFilter, error) {
+	f := &historyFilter{q: q}
+	if q.URLRegex != "" {
+		re, err := regexp.Compile(q.URLRegex)
+		if err != nil {
+			return nil, fmt.Errorf("urlRegex: %w", err)
+		}
+		f.urlRe = re
+	}
+	if len(q.Body) > 0 {
+		cs, err := compileStub(Mapping{ID: "history", Request: Request{Body: q.Body}}, 0)
+		if err != nil {
+			return nil, err
+		}
+		f.body = cs
+	}
+	if len(q.Tags) > 0 {
+		f.tags = make(map[string][]string)
+		for _, m := range Global.snapshot().mappings {
+			f.tags[m.ID] = m.Metadata.Tags
+		}
+	}
+
+	key, desc := strings.CutPrefix(q.Sort, "-")
+	if key == "" {
+		key = "time"
+	}
+	compare, ok := historySorts[key]
+	if !ok {
+		return nil, fmt.Errorf("cannot sort by %q", q.Sort)
+	}
+	f.compare = compare
+	if desc {
+		f.compare = func(a, b CallRecord) int { return compare(b, a) }
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return nil, errors.New("offset and limit must not be negative")
+	}
+	return f, nil
+}
+
+func (f *historyFilter) match(rec CallRecord) bool {
+	q := f.q
+	switch {
+	case q.Method != "" && !strings.EqualFold(q.Method, rec.Method),
+		q.URLPrefix != "" && !strings.HasPrefix(rec.URL, q.URLPrefix),
+		f.urlRe != nil && !f.urlRe.MatchString(rec.URL),
+		q.MappingID != "" && q.MappingID != rec.MappingID,
+		q.StatusMin != nil && rec.Status < *q.StatusMin,
+		q.StatusMax != nil && rec.Status > *q.StatusMax,
+		q.Unmatched && rec.MappingID != "",
+		!q.Since.IsZero() && rec.Time.Before(q.Since),
+		!q.Until.IsZero() && !rec.Time.Before(q.Until):
+		return false
+	}
+	if f.tags != nil && !slices.ContainsFunc(f.tags[rec.MappingID], func(t string) bool { return slices.Contains(q.Tags, t) }) {
+		return false
+	}
+	return f.body == nil || f.body.matches(rec.incoming())
+}
+
+// QueryCallHistory returns a page of the calls q selects and the number of
+// calls it selects before paging.
+func QueryCallHistory(q HistoryQuery) ([]CallRecord, int, error) {
+	f, err := q.compile()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	history.mu.RLock()
+	cutoff := history.cutoff(time.Now())
+	calls := []CallRecord{}
+	for i := 0; i < history.n; i++ {
+		rec := history.at(i).rec
+		if rec.Time.Before(cutoff) || !f.match(rec) {
+			continue
+		}
+		calls = append(calls, rec)
+	}
+	history.mu.RUnlock()
+
+	slices.SortStableFunc(calls, f.compare)
+	total := len(calls)
+	calls = calls[min(q.Offset, total):]
+	if q.Limit > 0 && len(calls) > q.Limit {
+		calls = calls[:q.Limit]
+	}
+	return calls, total, nil
+}
@@ -0,0 +1,88 @@
+package appdata
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test each history filter and sort order against a fixed set of calls.
+func TestQueryCallHistoryFilters(t *testing.T) {
+	withHistoryConfig(t, HistoryConfig{})
+	Global.Reset()
+	t.Cleanup(Global.Reset)
+	err := Global.Add(Mapping{
+		ID:       "pay",
+		Request:  Request{Method: "POST", URLPattern: "/payments"},
+		Metadata: Metadata{Tags: []string{"payments", "slow"}},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := []CallRecord{
+		{URL: "/payments", Method: "POST", MappingID: "pay", Status: 201, DurationMs: 30, RequestBody: map[string]any{"amount": 50.0, "currency": "EUR"}},
+		{URL: "/payments", Method: "POST", MappingID: "pay", Status: 503, DurationMs: 10, RequestBody: map[string]any{"amount": 5.0, "currency": "USD"}},
+		{URL: "/users/7", Method: "GET", MappingID: "user", Status: 200, DurationMs: 20},
+		{URL: "/missing", Method: "GET", Status: 404, DurationMs: 1},
+		{URL: "/slow", Method: "GET", Status: 0, DurationMs: 50},
+	}
+	for i, c := range calls {
+		c.Time = start.Add(time.Duration(i) * time.Minute)
+		RecordCall(c)
+	}
+
+	tests := []struct {
+		name string
+		q    HistoryQuery
+		want string // URLs with statuses, in order
+	}{
+		{"no filter", HistoryQuery{}, "/payments:201 /payments:503 /users/7:200 /missing:404 /slow:0"},
+		{"method", HistoryQuery{Method: "get"}, "/users/7:200 /missing:404 /slow:0"},
+		{"url prefix", HistoryQuery{URLPrefix: "/users/"}, "/users/7:200"},
+		{"url regex", HistoryQuery{URLRegex: `^/(users|missing)`}, "/users/7:200 /missing:404"},
+		{"mapping", HistoryQuery{MappingID: "user"}, "/users/7:200"},
+		{"status range", HistoryQuery{StatusMin: intPtr(400), StatusMax: intPtr(599)}, "/payments:503 /missing:404"},
+		{"status zero", HistoryQuery{StatusMin: intPtr(0), StatusMax: intPtr(0)}, "/slow:0"},
+		{"status at most", HistoryQuery{StatusMax: intPtr(201)}, "/payments:201 /users/7:200 /slow:0"},
+		{"unmatched", HistoryQuery{Unmatched: true}, "/missing:404 /slow:0"},
+		{"tag", HistoryQuery{Tags: []string{"other", "slow"}}, "/payments:201 /payments:503"},
+		{"time window", HistoryQuery{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)}, "/payments:503 /users/7:200"},
+		{"body literal", HistoryQuery{Body: map[string]any{"currency": "EUR"}}, "/payments:201"},
+		{"body operator", HistoryQuery{Body: map[string]any{"$.amount": map[string]any{"lessThan": 10}}}, "/payments:503"},
+		{"sort by duration", HistoryQuery{Sort: "duration"}, "/missing:404 /payments:503 /users/7:200 /payments:201 /slow:0"},
+		{"newest first, paged", HistoryQuery{Sort: "-time", Offset: 2, Limit: 2}, "/users/7:200 /payments:503"},
+		{"combined", HistoryQuery{Method: "POST", StatusMin: intPtr(500), Tags: []string{"payments"}}, "/payments:503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := QueryCallHistory(tt.q)
+			if err != nil {
+				t.Fatalf("QueryCallHistory error = %v", err)
+			}
+			var parts []string
+			for _, c := range got {
+				parts = append(parts, c.URL+":"+strconv.Itoa(c.Status))
+			}
+			if s := strings.Join(parts, " "); s != tt.want {
+				t.Fatalf("QueryCallHistory = %q, want %q", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryCallHistoryInvalid(t *testing.T) {
+	bad := []HistoryQuery{
+		{URLRegex: "("},
+		{Sort: "size"},
+		{Offset: -1},
+		{Body: map[string]any{"a": map[string]any{"matches": "("}}},
+	}
+	for _, q := range bad {
+		if _, _, err := QueryCallHistory(q); err == nil {
+			t.Errorf("QueryCallHistory(%+v) succeeded, want error", q)
+		}
+	}
+}
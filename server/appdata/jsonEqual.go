@@ -0,0 +1,115 @@
+package appdata
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BodyEqualToJSON requires the whole request body to equal a JSON document.
+type BodyEqualToJSON struct {
+	JSON any `json:"json"`
+	// IgnoreExtraElements allows extra object fields and extra array elements
+	// in the request that are not present in JSON.
+	IgnoreExtraElements bool `json:"ignoreExtraElements,omitempty"`
+	// IgnoreArrayOrder compares arrays as multisets.
+	IgnoreArrayOrder bool `json:"ignoreArrayOrder,omitempty"`
+}
+
+// jsonEqualMatcher is the compiled form of BodyEqualToJSON.
+type jsonEqualMatcher struct {
+	expected     any
+	ignoreExtra  bool
+	ignoreOrder  bool
+	signatureStr string
+}
+
+func compileJSONEqual(cfg *BodyEqualToJSON) (*jsonEqualMatcher, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	// Canonical encoding (map keys sorted) so identical fixtures share a body node.
+	b, err := json.Marshal(cfg.JSON)
+	if err != nil {
+		return nil, fmt.Errorf("bodyEqualToJson: %w", err)
+	}
+	return &jsonEqualMatcher{
+		expected:     cfg.JSON,
+		ignoreExtra:  cfg.IgnoreExtraElements,
+		ignoreOrder:  cfg.IgnoreArrayOrder,
+		signatureStr: fmt.Sprintf("equalToJson=%s;extra=%t;order=%t", b, cfg.IgnoreExtraElements, cfg.IgnoreArrayOrder),
+	}, nil
+}
+
+func (m *jsonEqualMatcher) signature() string {
+	if m == nil {
+		return ""
+	}
+	return m.signatureStr
+}
+
+func (m *jsonEqualMatcher) match(body any) bool {
+	return m.equal(body, m.expected)
+}
+
+func (m *jsonEqualMatcher) equal(actual, expected any) bool {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		if !m.ignoreExtra && len(a) != len(e) {
+			return false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !m.equal(av, ev) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok {
+			return false
+		}
+		if len(a) < len(e) || (!m.ignoreExtra && len(a) != len(e)) {
+			return false
+		}
+		if m.ignoreOrder {
+			return m.matchUnordered(a, e, make([]bool, len(a)))
+		}
+		// Ordered: expected elements must appear in order; with ignoreExtra
+		// the request may interleave additional elements.
+		i := 0
+		for _, av := range a {
+			if i < len(e) && m.equal(av, e[i]) {
+				i++
+			} else if !m.ignoreExtra {
+				return false
+			}
+		}
+		return i == len(e)
+	default:
+		return jpEqual(actual, expected)
+	}
+}
+
+// matchUnordered assigns each expected element to a distinct actual element,
+// backtracking when an early greedy choice blocks a later element.
+func (m *jsonEqualMatcher) matchUnordered(actual, expected []any, used []bool) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	for i, av := range actual {
+		if used[i] || !m.equal(av, expected[0]) {
+			continue
+		}
+		used[i] = true
+		if m.matchUnordered(actual, expected[1:], used) {
+			return true
+		}
+		used[i] = false
+	}
+	return false
+}
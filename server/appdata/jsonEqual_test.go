@@ -0,0 +1,68 @@
+package appdata
+
+import "testing"
+
+// Test whole-body equality with and without the leniency flags.
+func TestJSONEqualMatcher(t *testing.T) {
+	fixture := `{"id": "ord-1", "tags": ["a", "b"], "items": [{"sku": "A"}, {"sku": "B"}]}`
+
+	cases := []struct {
+		name        string
+		body        string
+		ignoreExtra bool
+		ignoreOrder bool
+		want        bool
+	}{
+		{"identical", fixture, false, false, true},
+		{"extra field rejected", `{"id": "ord-1", "tags": ["a", "b"], "items": [{"sku": "A"}, {"sku": "B"}], "x": 1}`, false, false, false},
+		{"extra field allowed", `{"id": "ord-1", "tags": ["a", "b"], "items": [{"sku": "A", "qty": 1}, {"sku": "B"}], "x": 1}`, true, false, true},
+		{"reordered rejected", `{"id": "ord-1", "tags": ["b", "a"], "items": [{"sku": "A"}, {"sku": "B"}]}`, false, false, false},
+		{"reordered allowed", `{"id": "ord-1", "tags": ["b", "a"], "items": [{"sku": "B"}, {"sku": "A"}]}`, false, true, true},
+		{"extra array element allowed", `{"id": "ord-1", "tags": ["a", "z", "b"], "items": [{"sku": "A"}, {"sku": "B"}]}`, true, false, true},
+		{"missing field", `{"id": "ord-1", "tags": ["a", "b"]}`, true, true, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := compileJSONEqual(&BodyEqualToJSON{
+				JSON:                decodeJSON(t, fixture),
+				IgnoreExtraElements: tc.ignoreExtra,
+				IgnoreArrayOrder:    tc.ignoreOrder,
+			})
+			if err != nil {
+				t.Fatalf("compileJSONEqual error = %v", err)
+			}
+			if got := m.match(decodeJSON(t, tc.body)); got != tc.want {
+				t.Fatalf("match(%s) = %v, want %v", tc.body, got, tc.want)
+			}
+		})
+	}
+}
+
+// Test that stubs with the same equalToJson fixture share a body node.
+func TestRuntimeIndexBodyEqualToJSONSharesNode(t *testing.T) {
+	ri := NewRuntimeIndex()
+	for _, id := range []string{"a", "b"} {
+		m := Mapping{
+			ID: id,
+			Request: Request{
+				Method:          "POST",
+				URLPattern:      "/orders",
+				BodyEqualToJSON: &BodyEqualToJSON{JSON: map[string]any{"id": "ord-1"}},
+			},
+		}
+		if err := ri.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", id, err)
+		}
+	}
+
+	qn := ri.snapshot().methods["POST"].urls[0].queries[""]
+	if len(qn.bodies) != 1 {
+		t.Fatalf("len(bodies) = %d, want 1 shared body node", len(qn.bodies))
+	}
+
+	req := IncomingRequest{Method: "POST", URL: "/orders", Body: map[string]any{"id": "ord-1"}}
+	if got, ok := ri.FindBestMatch(req); !ok || got.ID != "a" {
+		t.Fatalf("FindBestMatch = %q, %v, want a (load order)", got.ID, ok)
+	}
+}
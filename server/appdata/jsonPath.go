@@ -0,0 +1,685 @@
+package appdata
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression evaluated against decoded JSON
+// (map[string]any / []any / scalars). Supported syntax:
+//
+//	$.store.book        child names (also ['name'] and ['a','b'])
+//	$..sku              recursive descent
+//	$.items[*], $.a.*   wildcards
+//	$.items[0], [-1]    indexes and unions ([0,2])
+//	$.items[1:3]        slices ([start:end:step])
+//	$.items[?(@.qty > 2 && @.type != 'gift')]
+//	                    filters with ==, !=, <, <=, >, >=, =~ /re/i, &&, ||, !
+//	                    and existence checks such as [?(@.discount)]
+type jsonPath struct {
+	src   string
+	steps []jpStep
+}
+
+type jpStepKind int
+
+const (
+	jpChild jpStepKind = iota
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+type jpStep struct {
+	kind       jpStepKind
+	descendant bool // preceded by ".."
+
+	names   []string
+	indexes []int
+
+	start, end, step int
+	hasStart, hasEnd bool
+	filter           jpExpr
+}
+
+func compileJSONPath(expr string) (*jsonPath, error) {
+	p := &jpParser{src: expr}
+	p.skipSpace()
+	if !p.consume('$') {
+		return nil, fmt.Errorf("jsonpath %q: must start with '$'", expr)
+	}
+	steps, err := p.parseSteps()
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath %q: %w", expr, err)
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, fmt.Errorf("jsonpath %q: unexpected %q at offset %d", expr, p.src[p.pos:], p.pos)
+	}
+	return &jsonPath{src: expr, steps: steps}, nil
+}
+
+// eval returns every node selected by the path, in document order.
+func (jp *jsonPath) eval(root any) []any {
+	return evalSteps(jp.steps, root, root)
+}
+
+func evalSteps(steps []jpStep, cur, root any) []any {
+	nodes := []any{cur}
+	for _, st := range steps {
+		var next []any
+		for _, n := range nodes {
+			if st.descendant {
+				for _, d := range descendants(n) {
+					next = st.apply(d, root, next)
+				}
+				continue
+			}
+			next = st.apply(n, root, next)
+		}
+		nodes = next
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+func (st jpStep) apply(n, root any, out []any) []any {
+	switch st.kind {
+	case jpChild:
+		if obj, ok := n.(map[string]any); ok {
+			for _, name := range st.names {
+				if v, ok := obj[name]; ok {
+					out = append(out, v)
+				}
+			}
+		}
+	case jpWildcard:
+		out = append(out, children(n)...)
+	case jpIndex:
+		for _, i := range st.indexes {
+			if v, ok := indexArray(n, i); ok {
+				out = append(out, v)
+			}
+		}
+	case jpSlice:
+		arr, ok := n.([]any)
+		if !ok {
+			break
+		}
+		out = append(out, sliceArray(arr, st)...)
+	case jpFilter:
+		for _, c := range children(n) {
+			if st.filter.eval(c, root) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// children lists array elements, or object values in key order for determinism.
+func children(n any) []any {
+	switch v := n.(type) {
+	case []any:
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]any, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, v[k])
+		}
+		return out
+	}
+	return nil
+}
+
+// descendants returns n and every node below it, depth-first.
+func descendants(n any) []any {
+	out := []any{n}
+	for _, c := range children(n) {
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
+
+func sliceArray(arr []any, st jpStep) []any {
+	step := st.step
+	if step == 0 {
+		step = 1
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			i += len(arr)
+		}
+		return max(0, min(i, len(arr)))
+	}
+
+	var out []any
+	if step > 0 {
+		start, end := 0, len(arr)
+		if st.hasStart {
+			start = norm(st.start)
+		}
+		if st.hasEnd {
+			end = norm(st.end)
+		}
+		for i := start; i < end; i += step {
+			out = append(out, arr[i])
+		}
+		return out
+	}
+
+	// With a negative step the bounds clamp to [-1, len-1] (RFC 9535), so an
+	// end before the first element still includes element 0.
+	normDown := func(i int) int {
+		if i < 0 {
+			i += len(arr)
+		}
+		return max(-1, min(i, len(arr)-1))
+	}
+	start, end := len(arr)-1, -1
+	if st.hasStart {
+		start = normDown(st.start)
+	}
+	if st.hasEnd {
+		end = normDown(st.end)
+	}
+	for i := start; i > end; i += step {
+		out = append(out, arr[i])
+	}
+	return out
+}
+
+// ---- filter expressions ----
+
+type jpExpr interface {
+	eval(cur, root any) bool
+}
+
+type jpOr struct{ left, right jpExpr }
+
+func (e jpOr) eval(cur, root any) bool { return e.left.eval(cur, root) || e.right.eval(cur, root) }
+
+type jpAnd struct{ left, right jpExpr }
+
+func (e jpAnd) eval(cur, root any) bool { return e.left.eval(cur, root) && e.right.eval(cur, root) }
+
+type jpNot struct{ inner jpExpr }
+
+func (e jpNot) eval(cur, root any) bool { return !e.inner.eval(cur, root) }
+
+// jpExists is a bare operand in a filter: a path that selects something, or a truthy literal.
+type jpExists struct{ operand jpOperand }
+
+func (e jpExists) eval(cur, root any) bool {
+	if e.operand.path == nil {
+		return e.operand.literal != nil && e.operand.literal != false
+	}
+	return len(e.operand.values(cur, root)) > 0
+}
+
+type jpCompare struct {
+	op          string
+	left, right jpOperand
+	re          *regexp.Regexp // for =~
+}
+
+func (e jpCompare) eval(cur, root any) bool {
+	lv := e.left.values(cur, root)
+	if len(lv) == 0 {
+		return false
+	}
+	if e.op == "=~" {
+		s, ok := lv[0].(string)
+		return ok && e.re.MatchString(s)
+	}
+	rv := e.right.values(cur, root)
+	if len(rv) == 0 {
+		return false
+	}
+	a, b := lv[0], rv[0]
+
+	switch e.op {
+	case "==":
+		return jpEqual(a, b)
+	case "!=":
+		return !jpEqual(a, b)
+	}
+	c, ok := jpOrder(a, b)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// jpOperand is either a path relative to @ / $ or a literal value.
+type jpOperand struct {
+	path     []jpStep
+	relative bool
+	literal  any
+}
+
+func (o jpOperand) values(cur, root any) []any {
+	if o.path == nil {
+		return []any{o.literal}
+	}
+	start := root
+	if o.relative {
+		start = cur
+	}
+	return evalSteps(o.path, start, root)
+}
+
+func jpEqual(a, b any) bool {
+	if af, ok := a.(float64); ok {
+		bf, ok := b.(float64)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func jpOrder(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av < bv:
+			return -1, true
+		case av > bv:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	}
+	return 0, false
+}
+
+// ---- parser ----
+
+type jpParser struct {
+	src string
+	pos int
+}
+
+func (p *jpParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *jpParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *jpParser) consume(c byte) bool {
+	if p.peek() == c && !p.eof() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jpParser) consumeStr(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jpParser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// parseSteps reads path steps until something that cannot start a step.
+func (p *jpParser) parseSteps() ([]jpStep, error) {
+	var steps []jpStep
+	for !p.eof() {
+		switch {
+		case p.consumeStr(".."):
+			st, err := p.parseStepAfterDot()
+			if err != nil {
+				return nil, err
+			}
+			st.descendant = true
+			steps = append(steps, st)
+		case p.consume('.'):
+			st, err := p.parseStepAfterDot()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, st)
+		case p.peek() == '[':
+			st, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, st)
+		default:
+			return steps, nil
+		}
+	}
+	return steps, nil
+}
+
+func (p *jpParser) parseStepAfterDot() (jpStep, error) {
+	if p.consume('*') {
+		return jpStep{kind: jpWildcard}, nil
+	}
+	if p.peek() == '[' {
+		return p.parseBracket()
+	}
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(".[]()=!<>&|, \t", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return jpStep{}, fmt.Errorf("expected name at offset %d", start)
+	}
+	return jpStep{kind: jpChild, names: []string{p.src[start:p.pos]}}, nil
+}
+
+func (p *jpParser) parseBracket() (jpStep, error) {
+	p.consume('[')
+	p.skipSpace()
+
+	var st jpStep
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		st = jpStep{kind: jpWildcard}
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		paren := p.consume('(')
+		expr, err := p.parseOr()
+		if err != nil {
+			return jpStep{}, err
+		}
+		p.skipSpace()
+		if paren && !p.consume(')') {
+			return jpStep{}, fmt.Errorf("expected ')' at offset %d", p.pos)
+		}
+		st = jpStep{kind: jpFilter, filter: expr}
+	case c == '\'' || c == '"':
+		st = jpStep{kind: jpChild}
+		for {
+			p.skipSpace()
+			s, err := p.parseString()
+			if err != nil {
+				return jpStep{}, err
+			}
+			st.names = append(st.names, s)
+			p.skipSpace()
+			if !p.consume(',') {
+				break
+			}
+		}
+	default:
+		var err error
+		st, err = p.parseIndexOrSlice()
+		if err != nil {
+			return jpStep{}, err
+		}
+	}
+
+	p.skipSpace()
+	if !p.consume(']') {
+		return jpStep{}, fmt.Errorf("expected ']' at offset %d", p.pos)
+	}
+	return st, nil
+}
+
+func (p *jpParser) parseIndexOrSlice() (jpStep, error) {
+	end := strings.IndexByte(p.src[p.pos:], ']')
+	if end < 0 {
+		return jpStep{}, fmt.Errorf("unclosed '[' at offset %d", p.pos)
+	}
+	inner := p.src[p.pos : p.pos+end]
+	p.pos += end
+
+	if strings.Contains(inner, ":") {
+		parts := strings.Split(inner, ":")
+		if len(parts) > 3 {
+			return jpStep{}, fmt.Errorf("invalid slice [%s]", inner)
+		}
+		st := jpStep{kind: jpSlice}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return jpStep{}, fmt.Errorf("invalid slice [%s]", inner)
+			}
+			switch i {
+			case 0:
+				st.start, st.hasStart = n, true
+			case 1:
+				st.end, st.hasEnd = n, true
+			case 2:
+				st.step = n
+			}
+		}
+		return st, nil
+	}
+
+	st := jpStep{kind: jpIndex}
+	for _, part := range strings.Split(inner, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return jpStep{}, fmt.Errorf("invalid index [%s]", inner)
+		}
+		st.indexes = append(st.indexes, n)
+	}
+	return st, nil
+}
+
+func (p *jpParser) parseString() (string, error) {
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", fmt.Errorf("expected string at offset %d", p.pos)
+	}
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			if p.eof() {
+				return "", fmt.Errorf("dangling escape at offset %d", p.pos)
+			}
+			b.WriteByte(p.src[p.pos])
+			p.pos++
+		case quote:
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *jpParser) parseOr() (jpExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consumeStr("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jpOr{left: left, right: right}
+	}
+}
+
+func (p *jpParser) parseAnd() (jpExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consumeStr("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = jpAnd{left: left, right: right}
+	}
+}
+
+func (p *jpParser) parseUnary() (jpExpr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!=") {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return jpNot{inner: inner}, nil
+	}
+	if p.consume('(') {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(')') {
+			return nil, fmt.Errorf("expected ')' at offset %d", p.pos)
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *jpParser) parseComparison() (jpExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if !p.consumeStr(op) {
+			continue
+		}
+		p.skipSpace()
+		if op == "=~" {
+			re, err := p.parseRegex()
+			if err != nil {
+				return nil, err
+			}
+			return jpCompare{op: op, left: left, re: re}, nil
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return jpCompare{op: op, left: left, right: right}, nil
+	}
+	return jpExists{operand: left}, nil
+}
+
+func (p *jpParser) parseOperand() (jpOperand, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		steps, err := p.parseSteps()
+		if err != nil {
+			return jpOperand{}, err
+		}
+		if steps == nil {
+			steps = []jpStep{}
+		}
+		return jpOperand{path: steps, relative: c == '@'}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return jpOperand{literal: s}, err
+	case p.consumeStr("true"):
+		return jpOperand{literal: true}, nil
+	case p.consumeStr("false"):
+		return jpOperand{literal: false}, nil
+	case p.consumeStr("null"):
+		return jpOperand{literal: nil}, nil
+	}
+
+	start := p.pos
+	for !p.eof() && strings.ContainsRune("+-.0123456789eE", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		return jpOperand{}, fmt.Errorf("expected operand at offset %d", start)
+	}
+	return jpOperand{literal: n}, nil
+}
+
+// parseRegex reads /pattern/flags; the only supported flag is i.
+func (p *jpParser) parseRegex() (*regexp.Regexp, error) {
+	if !p.consume('/') {
+		return nil, fmt.Errorf("expected /regex/ at offset %d", p.pos)
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return nil, fmt.Errorf("unterminated regex")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c == '\\' && p.peek() == '/' {
+			b.WriteByte('/')
+			p.pos++
+			continue
+		}
+		if c == '/' {
+			break
+		}
+		b.WriteByte(c)
+	}
+	pattern := b.String()
+	if p.consume('i') {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
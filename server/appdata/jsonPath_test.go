@@ -0,0 +1,108 @@
+package appdata
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const orderJSON = `{
+  "id": "ord-1",
+  "customer": {"email": "a@example.com", "tier": "gold"},
+  "items": [
+    {"sku": "A-1", "qty": 1, "type": "standard"},
+    {"sku": "B-2", "qty": 3, "type": "gift", "discount": 5},
+    {"sku": "C-3", "qty": 5, "type": "standard"}
+  ]
+}`
+
+func decodeJSON(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return v
+}
+
+// Test JSONPath selection over a representative order payload.
+func TestJSONPathEval(t *testing.T) {
+	body := decodeJSON(t, orderJSON)
+
+	cases := []struct {
+		expr string
+		want []any
+	}{
+		{"$.id", []any{"ord-1"}},
+		{"$['customer']['email']", []any{"a@example.com"}},
+		{"$.items[0].sku", []any{"A-1"}},
+		{"$.items[-1].sku", []any{"C-3"}},
+		{"$.items[0,2].sku", []any{"A-1", "C-3"}},
+		{"$.items[1:].sku", []any{"B-2", "C-3"}},
+		{"$.items[::-1].sku", []any{"C-3", "B-2", "A-1"}},
+		{"$.items[2:0:-1].sku", []any{"C-3", "B-2"}},
+		{"$.items[-1:-10:-1].sku", []any{"C-3", "B-2", "A-1"}},
+		{"$.items[0:-10:-1].sku", []any{"A-1"}},
+		{"$.items[10::-2].sku", []any{"C-3", "A-1"}},
+		{"$.items[-10::-1].sku", nil},
+		{"$.items[*].sku", []any{"A-1", "B-2", "C-3"}},
+		{"$..sku", []any{"A-1", "B-2", "C-3"}},
+		{"$.items[?(@.qty > 2)].sku", []any{"B-2", "C-3"}},
+		{"$.items[?(@.qty > 2 && @.type == 'standard')].sku", []any{"C-3"}},
+		{"$.items[?(@.discount)].sku", []any{"B-2"}},
+		{"$.items[?(!@.discount)].sku", []any{"A-1", "C-3"}},
+		{"$.items[?(@.sku =~ /^b-/i)].qty", []any{float64(3)}},
+		{"$.items[?(@.type == $.customer.tier)].sku", nil},
+		{"$.missing", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			jp, err := compileJSONPath(tc.expr)
+			if err != nil {
+				t.Fatalf("compileJSONPath(%q) error = %v", tc.expr, err)
+			}
+			if got := jp.eval(body); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("eval(%q) = %v, want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+}
+
+// Test that JSONPath keys in request.body drive stub selection.
+func TestRuntimeIndexJSONPathBodyMatcher(t *testing.T) {
+	ri := NewRuntimeIndex()
+	m := Mapping{
+		ID: "bulk-gift",
+		Request: Request{
+			Method:     "POST",
+			URLPattern: "/orders",
+			Body: map[string]any{
+				"$.items[?(@.qty > 2)].type": "gift",
+				"$.coupon":                   map[string]any{"exists": false},
+			},
+		},
+	}
+	if err := ri.Add(m); err != nil {
+		t.Fatalf("Add(bulk-gift) error = %v", err)
+	}
+
+	req := IncomingRequest{Method: "POST", URL: "/orders", Body: decodeJSON(t, orderJSON)}
+	if got, ok := ri.FindBestMatch(req); !ok || got.ID != "bulk-gift" {
+		t.Fatalf("FindBestMatch = %q, %v, want bulk-gift", got.ID, ok)
+	}
+
+	req.Body = decodeJSON(t, `{"items": [{"qty": 9, "type": "standard"}]}`)
+	if _, ok := ri.FindBestMatch(req); ok {
+		t.Fatalf("FindBestMatch matched a body without bulk gift items")
+	}
+}
+
+// Test that malformed expressions are rejected at compile time.
+func TestCompileJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{"items", "$.items[", "$.items[?(@.qty >)]", "$.items[?(@.a =~ /(/)]", "$.a b"} {
+		if _, err := compileJSONPath(expr); err == nil {
+			t.Errorf("compileJSONPath(%q) error = nil, want error", expr)
+		}
+	}
+}
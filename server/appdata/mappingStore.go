@@ -0,0 +1,171 @@
+package appdata
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrMappingNotFound = errors.New("mapping not found")
+	ErrMappingExists   = errors.New("mapping already exists")
+	// ErrMappingFromFile rejects runtime changes to a mapping loaded from the
+	// mocks directory, which the next reload would quietly undo.
+	ErrMappingFromFile = errors.New("mapping is defined in the mocks directory")
+)
+
+// runtimeIDs tracks mappings installed through the admin API, so that a
+// reload of the mocks directory keeps them; fileIDs tracks the mappings the
+// last reload loaded from files.
+var (
+	storeMu    sync.Mutex
+	runtimeIDs = make(map[string]bool)
+	fileIDs    = make(map[string]bool)
+)
+
+// ValidationError wraps a mapping that RuntimeIndex.Add rejected.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// GetMapping returns the registered mapping with the given id.
+func GetMapping(id string) (Mapping, bool) {
+	for _, m := range Global.snapshot().mappings {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Mapping{}, false
+}
+
+// CreateMapping registers a new mapping at runtime. An empty id is generated.
+func CreateMapping(m Mapping) (Mapping, error) {
+	if strings.TrimSpace(m.ID) == "" {
+		m.ID = newMappingID()
+	}
+	err := updateMappings(func(list []Mapping) ([]Mapping, error) {
+		if indexOfMapping(list, m.ID) >= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrMappingExists, m.ID)
+		}
+		return append(list, m), nil
+	}, m, func() { runtimeIDs[m.ID] = true })
+	return m, err
+}
+
+// UpdateMapping replaces the mapping with the given id, keeping its load order.
+// Its responses list starts over from the first entry. Mappings loaded from
+// files are rejected with ErrMappingFromFile.
+func UpdateMapping(id string, m Mapping) (Mapping, error) {
+	if strings.TrimSpace(m.ID) == "" {
+		m.ID = id
+	}
+	if m.ID != id {
+		return Mapping{}, &ValidationError{Err: fmt.Errorf("mapping id %q does not match %q", m.ID, id)}
+	}
+	err := updateMappings(func(list []Mapping) ([]Mapping, error) {
+		i := indexOfMapping(list, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrMappingNotFound, id)
+		}
+		if fileIDs[id] {
+			return nil, fmt.Errorf("%w: %q", ErrMappingFromFile, id)
+		}
+		list[i] = m
+		return list, nil
+	}, m, func() { Global.ResetMappingResponses(id) })
+	return m, err
+}
+
+// DeleteMapping removes the mapping with the given id. Mappings loaded from
+// files are rejected with ErrMappingFromFile.
+func DeleteMapping(id string) error {
+	return updateMappings(func(list []Mapping) ([]Mapping, error) {
+		i := indexOfMapping(list, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrMappingNotFound, id)
+		}
+		if fileIDs[id] {
+			return nil, fmt.Errorf("%w: %q", ErrMappingFromFile, id)
+		}
+		return append(list[:i], list[i+1:]...), nil
+	}, Mapping{}, func() { delete(runtimeIDs, id) })
+}
+
+// ReplaceFileMappings publishes a freshly loaded set of file mappings.
+// Mappings installed through the admin API survive unless a file now defines
+// the same id. On error the current mappings stay in place.
+func ReplaceFileMappings(files []Mapping) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	loaded := make(map[string]bool, len(files))
+	err := Global.Replace(func(current []Mapping) ([]Mapping, error) {
+		list := make([]Mapping, 0, len(files))
+		for _, m := range files {
+			if loaded[m.ID] {
+				return nil, &ValidationError{Err: fmt.Errorf("duplicate mapping id %q", m.ID)}
+			}
+			loaded[m.ID] = true
+			list = append(list, m)
+		}
+		for _, m := range current {
+			if runtimeIDs[m.ID] && !loaded[m.ID] {
+				list = append(list, m)
+			}
+		}
+		return list, nil
+	})
+	if err != nil {
+		return err
+	}
+	for id := range loaded {
+		delete(runtimeIDs, id)
+	}
+	fileIDs = loaded
+	return nil
+}
+
+// updateMappings applies change to a copy of the registered mappings and
+// publishes a fresh snapshot built from the result. storeMu keeps concurrent
+// admin writes ordered; commit (optional) runs under it once published.
+func updateMappings(change func([]Mapping) ([]Mapping, error), changed Mapping, commit func()) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	err := Global.Replace(func(current []Mapping) ([]Mapping, error) {
+		if changed.ID != "" {
+			if err := CheckBodyFile(changed); err != nil {
+				return nil, err
+			}
+		}
+		return change(current)
+	})
+	if err != nil {
+		return err
+	}
+	if commit != nil {
+		commit()
+	}
+	return nil
+}
+
+func indexOfMapping(list []Mapping, id string) int {
+	for i, m := range list {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func newMappingID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "mapping-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,164 @@
+package appdata
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func resetGlobalMappings(t *testing.T) {
+	t.Helper()
+	Global.Reset()
+	t.Cleanup(Global.Reset)
+}
+
+// Test that runtime create/update/delete keep the index and the admin
+// snapshot in sync, and reject invalid mappings without side effects.
+func TestMappingStoreCRUD(t *testing.T) {
+	resetGlobalMappings(t)
+
+	req := IncomingRequest{Method: "GET", URL: "/orders"}
+	m := Mapping{ID: "orders", Request: Request{Method: "GET", URLPattern: "/orders"}, Response: Response{Status: 200}}
+
+	if _, err := CreateMapping(m); err != nil {
+		t.Fatalf("CreateMapping error = %v", err)
+	}
+	if _, err := CreateMapping(m); !errors.Is(err, ErrMappingExists) {
+		t.Fatalf("CreateMapping(duplicate) error = %v, want ErrMappingExists", err)
+	}
+	if got, ok := Global.FindBestMatch(req); !ok || got.Response.Status != 200 {
+		t.Fatalf("FindBestMatch after create = %+v, %v", got, ok)
+	}
+
+	m.Response.Status = 503
+	if _, err := UpdateMapping("orders", m); err != nil {
+		t.Fatalf("UpdateMapping error = %v", err)
+	}
+	if got, _ := Global.FindBestMatch(req); got.Response.Status != 503 {
+		t.Fatalf("FindBestMatch after update status = %d, want 503", got.Response.Status)
+	}
+
+	bad := m
+	bad.Request.Method = ""
+	var verr *ValidationError
+	if _, err := UpdateMapping("orders", bad); !errors.As(err, &verr) {
+		t.Fatalf("UpdateMapping(invalid) error = %v, want ValidationError", err)
+	}
+	if got, _ := GetMapping("orders"); got.Response.Status != 503 {
+		t.Fatalf("GetMapping after rejected update status = %d, want 503", got.Response.Status)
+	}
+
+	if err := DeleteMapping("orders"); err != nil {
+		t.Fatalf("DeleteMapping error = %v", err)
+	}
+	if _, ok := Global.FindBestMatch(req); ok {
+		t.Fatalf("FindBestMatch after delete matched, want no match")
+	}
+	if n := len(GetAllMappings()); n != 0 {
+		t.Fatalf("len(GetAllMappings()) = %d, want 0", n)
+	}
+	if err := DeleteMapping("orders"); !errors.Is(err, ErrMappingNotFound) {
+		t.Fatalf("DeleteMapping(missing) error = %v, want ErrMappingNotFound", err)
+	}
+}
+
+// Test that a file reload replaces file mappings, keeps runtime ones unless
+// a file now defines the same id, and changes nothing on error.
+func TestReplaceFileMappings(t *testing.T) {
+	resetGlobalMappings(t)
+
+	file := func(id, url string) Mapping {
+		return Mapping{ID: id, Request: Request{Method: "GET", URLPattern: url}, Response: Response{Status: 200}}
+	}
+	matched := func(url string) string {
+		res, ok := Global.Match(IncomingRequest{Method: "GET", URL: url})
+		if !ok {
+			return ""
+		}
+		return res.Mapping.ID
+	}
+
+	if err := ReplaceFileMappings([]Mapping{file("rfm-a", "/a"), file("rfm-b", "/b")}); err != nil {
+		t.Fatalf("ReplaceFileMappings error = %v", err)
+	}
+	if _, err := CreateMapping(file("rfm-runtime", "/runtime")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateMapping(file("rfm-shadowed", "/shadow-old")); err != nil {
+		t.Fatal(err)
+	}
+
+	// rfm-b is gone from the files; rfm-shadowed is now defined by a file.
+	if err := ReplaceFileMappings([]Mapping{file("rfm-a", "/a"), file("rfm-shadowed", "/shadow-new")}); err != nil {
+		t.Fatalf("ReplaceFileMappings error = %v", err)
+	}
+	for url, want := range map[string]string{"/a": "rfm-a", "/b": "", "/runtime": "rfm-runtime", "/shadow-old": "", "/shadow-new": "rfm-shadowed"} {
+		if got := matched(url); got != want {
+			t.Errorf("%s matched %q, want %q", url, got, want)
+		}
+	}
+
+	err := ReplaceFileMappings([]Mapping{file("rfm-dup", "/x"), file("rfm-dup", "/y")})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("duplicate ids error = %v, want validation error", err)
+	}
+	if matched("/a") != "rfm-a" || matched("/x") != "" {
+		t.Fatal("failed replace changed the index")
+	}
+}
+
+// Test that file mappings refuse runtime changes, so a reload cannot quietly
+// undo an accepted PUT or DELETE.
+func TestFileMappingsRejectRuntimeChanges(t *testing.T) {
+	resetGlobalMappings(t)
+
+	file := Mapping{ID: "rfm-file", Request: Request{Method: "GET", URLPattern: "/file"}, Response: Response{Status: 200}}
+	if err := ReplaceFileMappings([]Mapping{file}); err != nil {
+		t.Fatalf("ReplaceFileMappings error = %v", err)
+	}
+
+	changed := file
+	changed.Response.Status = 503
+	if _, err := UpdateMapping("rfm-file", changed); !errors.Is(err, ErrMappingFromFile) {
+		t.Fatalf("UpdateMapping(file) error = %v, want ErrMappingFromFile", err)
+	}
+	if err := DeleteMapping("rfm-file"); !errors.Is(err, ErrMappingFromFile) {
+		t.Fatalf("DeleteMapping(file) error = %v, want ErrMappingFromFile", err)
+	}
+
+	if err := ReplaceFileMappings([]Mapping{file}); err != nil {
+		t.Fatalf("ReplaceFileMappings error = %v", err)
+	}
+	if got, ok := GetMapping("rfm-file"); !ok || got.Response.Status != 200 {
+		t.Fatalf("GetMapping after reload = %+v, %v, want the file version", got, ok)
+	}
+
+	// A file that is gone frees its id for runtime changes.
+	if err := ReplaceFileMappings(nil); err != nil {
+		t.Fatalf("ReplaceFileMappings error = %v", err)
+	}
+	if _, err := CreateMapping(file); err != nil {
+		t.Fatalf("CreateMapping error = %v", err)
+	}
+	if _, err := UpdateMapping("rfm-file", changed); err != nil {
+		t.Fatalf("UpdateMapping(runtime) error = %v", err)
+	}
+}
+
+// Test that replacing a mapping's responses list restarts its sequence.
+func TestUpdateMappingRestartsResponses(t *testing.T) {
+	resetGlobalMappings(t)
+
+	if _, err := CreateMapping(responsesMapping(ResponseModeSequence, nil, 500, 502, 503)); err != nil {
+		t.Fatalf("CreateMapping error = %v", err)
+	}
+	renderStatuses(t, Global, 2)
+
+	if _, err := UpdateMapping("retry", responsesMapping(ResponseModeSequence, nil, 201, 202)); err != nil {
+		t.Fatalf("UpdateMapping error = %v", err)
+	}
+	if got := renderStatuses(t, Global, 2); !slices.Equal(got, []int{201, 202}) {
+		t.Fatalf("statuses after update = %v, want [201 202]", got)
+	}
+}
@@ -0,0 +1,349 @@
+package appdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// valueMatcher is a compiled predicate over a single request value.
+// present is false when the field/param/header does not exist at all, which
+// lets operators like "absent" and "not" reason about missing values.
+type valueMatcher interface {
+	match(actual any, present bool) bool
+}
+
+// Supported operator keys. A matcher object whose keys are all operators is
+// compiled into a predicate; anything else is treated as a literal for equalTo.
+const (
+	opEqualTo            = "equalTo"
+	opContains           = "contains"
+	opMatches            = "matches"
+	opOneOf              = "oneOf"
+	opAbsent             = "absent"
+	opExists             = "exists"
+	opNot                = "not"
+	opGreaterThan        = "greaterThan"
+	opGreaterThanOrEqual = "greaterThanOrEqual"
+	opLessThan           = "lessThan"
+	opLessThanOrEqual    = "lessThanOrEqual"
+)
+
+var knownOperators = map[string]bool{
+	opEqualTo:            true,
+	opContains:           true,
+	opMatches:            true,
+	opOneOf:              true,
+	opAbsent:             true,
+	opExists:             true,
+	opNot:                true,
+	opGreaterThan:        true,
+	opGreaterThanOrEqual: true,
+	opLessThan:           true,
+	opLessThanOrEqual:    true,
+}
+
+// isOperatorObject reports whether v is a non-empty object made only of operator keys.
+func isOperatorObject(v any) (map[string]any, bool) {
+	obj, ok := v.(map[string]any)
+	if !ok || len(obj) == 0 {
+		return nil, false
+	}
+	for k := range obj {
+		if !knownOperators[k] {
+			return nil, false
+		}
+	}
+	return obj, true
+}
+
+// compileValueMatcher turns a configured expectation into a valueMatcher.
+// Plain values are shorthand for {"equalTo": value}. Several operators in one
+// object must all hold, e.g. {"greaterThan": 1, "lessThan": 10}.
+func compileValueMatcher(expected any) (valueMatcher, error) {
+	obj, ok := isOperatorObject(expected)
+	if !ok {
+		return equalToMatcher{expected: expected}, nil
+	}
+
+	ops := make([]string, 0, len(obj))
+	for k := range obj {
+		ops = append(ops, k)
+	}
+	sort.Strings(ops)
+
+	var all allOfMatcher
+	for _, op := range ops {
+		m, err := compileOperator(op, obj[op])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		all = append(all, m)
+	}
+	if len(all) == 1 {
+		return all[0], nil
+	}
+	return all, nil
+}
+
+func compileOperator(op string, arg any) (valueMatcher, error) {
+	switch op {
+	case opEqualTo:
+		return equalToMatcher{expected: arg}, nil
+	case opContains:
+		return containsMatcher{expected: arg}, nil
+	case opMatches:
+		s, ok := arg.(string)
+		if !ok {
+			return nil, errors.New("expects a regex string")
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		return regexMatcher{re: re}, nil
+	case opOneOf:
+		values, ok := arg.([]any)
+		if !ok {
+			return nil, errors.New("expects an array")
+		}
+		return oneOfMatcher{values: values}, nil
+	case opAbsent:
+		b, ok := arg.(bool)
+		if !ok {
+			return nil, errors.New("expects a boolean")
+		}
+		return absentMatcher{absent: b}, nil
+	case opExists:
+		b, ok := arg.(bool)
+		if !ok {
+			return nil, errors.New("expects a boolean")
+		}
+		return absentMatcher{absent: !b}, nil
+	case opNot:
+		inner, err := compileValueMatcher(arg)
+		if err != nil {
+			return nil, err
+		}
+		return notMatcher{inner: inner}, nil
+	case opGreaterThan, opGreaterThanOrEqual, opLessThan, opLessThanOrEqual:
+		n, ok := toFloat(arg)
+		if !ok {
+			return nil, errors.New("expects a number")
+		}
+		return numberMatcher{op: op, bound: n}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+type equalToMatcher struct{ expected any }
+
+func (m equalToMatcher) match(actual any, present bool) bool {
+	return present && valuesEqual(actual, m.expected)
+}
+
+// containsMatcher checks substring containment for strings and element
+// membership for arrays.
+type containsMatcher struct{ expected any }
+
+func (m containsMatcher) match(actual any, present bool) bool {
+	if !present {
+		return false
+	}
+	if arr, ok := actual.([]any); ok {
+		for _, el := range arr {
+			if valuesEqual(el, m.expected) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(stringify(actual), stringify(m.expected))
+}
+
+type regexMatcher struct{ re *regexp.Regexp }
+
+func (m regexMatcher) match(actual any, present bool) bool {
+	return present && m.re.MatchString(stringify(actual))
+}
+
+type oneOfMatcher struct{ values []any }
+
+func (m oneOfMatcher) match(actual any, present bool) bool {
+	if !present {
+		return false
+	}
+	for _, v := range m.values {
+		if valuesEqual(actual, v) {
+			return true
+		}
+	}
+	return false
+}
+
+type absentMatcher struct{ absent bool }
+
+func (m absentMatcher) match(_ any, present bool) bool {
+	return present != m.absent
+}
+
+type notMatcher struct{ inner valueMatcher }
+
+func (m notMatcher) match(actual any, present bool) bool {
+	return !m.inner.match(actual, present)
+}
+
+type numberMatcher struct {
+	op    string
+	bound float64
+}
+
+func (m numberMatcher) match(actual any, present bool) bool {
+	if !present {
+		return false
+	}
+	n, ok := toFloat(actual)
+	if !ok {
+		return false
+	}
+	switch m.op {
+	case opGreaterThan:
+		return n > m.bound
+	case opGreaterThanOrEqual:
+		return n >= m.bound
+	case opLessThan:
+		return n < m.bound
+	case opLessThanOrEqual:
+		return n <= m.bound
+	}
+	return false
+}
+
+type allOfMatcher []valueMatcher
+
+func (m allOfMatcher) match(actual any, present bool) bool {
+	for _, inner := range m {
+		if !inner.match(actual, present) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyMatcher is a compiled requirement on a multi-valued key (query param or header).
+type keyMatcher struct {
+	Key      string
+	Expected any
+	m        valueMatcher
+}
+
+// matchValues succeeds when any of the values satisfies the matcher, or when
+// the key is missing and the matcher accepts missing values (e.g. absent).
+// A "not" applies to the whole set: no value may satisfy its inner matcher.
+func (km keyMatcher) matchValues(values []string, present bool) bool {
+	if !present {
+		return km.m.match(nil, false)
+	}
+	return matchValueSet(km.m, values)
+}
+
+func matchValueSet(m valueMatcher, values []string) bool {
+	switch mm := m.(type) {
+	case notMatcher:
+		return !matchValueSet(mm.inner, values)
+	case allOfMatcher:
+		// Negations hold over the set; the other operators must all hold
+		// for one value.
+		var rest allOfMatcher
+		for _, inner := range mm {
+			if not, ok := inner.(notMatcher); ok {
+				if !matchValueSet(not, values) {
+					return false
+				}
+				continue
+			}
+			rest = append(rest, inner)
+		}
+		if len(rest) == 0 {
+			return true
+		}
+		m = rest
+	}
+	for _, v := range values {
+		if m.match(v, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (km keyMatcher) signature() string {
+	return canonicalJSON(km.Key) + "=" + canonicalJSON(km.Expected)
+}
+
+// canonicalJSON encodes a matcher value with sorted map keys, so equal
+// matchers share a signature and different ones never do (fmt.Sprint prints
+// ["New York"] and ["New","York"] alike).
+func canonicalJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(b)
+}
+
+// compileKeyMatchers compiles a query/header requirement map into sorted matchers.
+// normalize is applied to each key (e.g. header canonicalization).
+func compileKeyMatchers(in map[string]any, normalize func(string) string) ([]keyMatcher, error) {
+	out := make([]keyMatcher, 0, len(in))
+	for k, v := range in {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		if normalize != nil {
+			k = normalize(k)
+		}
+		if s, ok := v.(string); ok {
+			v = strings.TrimSpace(s)
+		}
+		m, err := compileValueMatcher(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", k, err)
+		}
+		out = append(out, keyMatcher{Key: k, Expected: v, m: m})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Key == out[j].Key {
+			return canonicalJSON(out[i].Expected) < canonicalJSON(out[j].Expected)
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
+
+func stringify(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package appdata
+
+import "testing"
+
+// Test that each operator compiles and evaluates as documented.
+func TestCompileValueMatcherOperators(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected any
+		actual   any
+		present  bool
+		want     bool
+	}{
+		{"plain value is equalTo", "ALL", "ALL", true, true},
+		{"plain value missing", "ALL", nil, false, false},
+		{"equalTo number vs string", map[string]any{"equalTo": float64(123)}, "123", true, true},
+		{"contains substring", map[string]any{"contains": "ord"}, "my-order", true, true},
+		{"contains array element", map[string]any{"contains": "gift"}, []any{"std", "gift"}, true, true},
+		{"matches regex", map[string]any{"matches": `^ord-\d+$`}, "ord-42", true, true},
+		{"matches regex miss", map[string]any{"matches": `^ord-\d+$`}, "ord-x", true, false},
+		{"oneOf hit", map[string]any{"oneOf": []any{"ios", "android"}}, "ios", true, true},
+		{"oneOf miss", map[string]any{"oneOf": []any{"ios", "android"}}, "web", true, false},
+		{"absent when missing", map[string]any{"absent": true}, nil, false, true},
+		{"absent when present", map[string]any{"absent": true}, "x", true, false},
+		{"greaterThan", map[string]any{"greaterThan": float64(10)}, float64(11), true, true},
+		{"greaterThan string number", map[string]any{"greaterThan": float64(10)}, "9", true, false},
+		{"range", map[string]any{"greaterThanOrEqual": float64(1), "lessThan": float64(5)}, float64(5), true, false},
+		{"not equal", map[string]any{"not": "x"}, "y", true, true},
+		{"not operator", map[string]any{"not": map[string]any{"oneOf": []any{"a", "b"}}}, "a", true, false},
+		{"literal object is equalTo", map[string]any{"foo": "bar"}, map[string]any{"foo": "bar"}, true, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := compileValueMatcher(tc.expected)
+			if err != nil {
+				t.Fatalf("compileValueMatcher(%v) error = %v", tc.expected, err)
+			}
+			if got := m.match(tc.actual, tc.present); got != tc.want {
+				t.Fatalf("match(%v, %v) = %v, want %v", tc.actual, tc.present, got, tc.want)
+			}
+		})
+	}
+}
+
+// Test that repeated query/header values pass when any value matches, while
+// "not" must hold for every value.
+func TestKeyMatcherRepeatedValues(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected any
+		values   []string
+		want     bool
+	}{
+		{"equalTo any value", "x", []string{"y", "x"}, true},
+		{"not equalTo one of them", map[string]any{"not": map[string]any{"equalTo": "x"}}, []string{"x", "y"}, false},
+		{"not equalTo none of them", map[string]any{"not": map[string]any{"equalTo": "x"}}, []string{"y", "z"}, true},
+		{"not oneOf alongside others", map[string]any{"not": map[string]any{"oneOf": []any{"a", "b"}}}, []string{"c", "a"}, false},
+		{"not not is any", map[string]any{"not": map[string]any{"not": "x"}}, []string{"y", "x"}, true},
+		{"not with exists", map[string]any{"exists": true, "not": "x"}, []string{"y", "x"}, false},
+		{"range on one value", map[string]any{"greaterThan": float64(1), "lessThan": float64(10)}, []string{"0", "20"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			kms, err := compileKeyMatchers(map[string]any{"n": tc.expected}, nil)
+			if err != nil {
+				t.Fatalf("compileKeyMatchers(%v) error = %v", tc.expected, err)
+			}
+			if got := kms[0].matchValues(tc.values, true); got != tc.want {
+				t.Fatalf("matchValues(%q) = %v, want %v", tc.values, got, tc.want)
+			}
+		})
+	}
+}
+
+// Test that invalid operator arguments are rejected at Add time.
+func TestRuntimeIndexAddRejectsInvalidOperator(t *testing.T) {
+	ri := NewRuntimeIndex()
+	m := Mapping{
+		ID: "bad-regex",
+		Request: Request{
+			Method:      "GET",
+			URLPattern:  "/orders",
+			QueryParams: map[string]any{"id": map[string]any{"matches": "("}},
+		},
+	}
+	if err := ri.Add(m); err == nil {
+		t.Fatalf("Add(bad-regex) error = nil, want compile error")
+	}
+}
@@ -0,0 +1,214 @@
+package appdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NearMiss is a mapping that almost matched a request. Distance is the share
+// of the mapping's checks that failed (0 would be a match, 1 nothing
+// matched); Reasons name each failing check, e.g.
+// "query userId expected 123 got 124".
+type NearMiss struct {
+	MappingID   string   `json:"mappingId"`
+	Description string   `json:"description,omitempty"`
+	Distance    float64  `json:"distance"`
+	Reasons     []string `json:"reasons"`
+}
+
+// checkTally accumulates the outcome of one level of the tree walk.
+type checkTally struct {
+	total   int
+	reasons []string
+}
+
+func (t checkTally) plus(o checkTally) checkTally {
+	return checkTally{
+		total:   t.total + o.total,
+		reasons: append(append([]string(nil), t.reasons...), o.reasons...),
+	}
+}
+
+// NearMisses returns up to limit mappings closest to matching req, closest
+// first. It walks the same urlNode, queryNode and bodyNode levels as Match,
+// but scores every stub instead of stopping at the first failed check.
+func (ri *RuntimeIndex) NearMisses(req IncomingRequest, limit int) []NearMiss {
+	snap := ri.snapshot()
+	states := ri.scenarios.copyStates()
+	method := strings.ToUpper(strings.TrimSpace(req.Method))
+
+	type candidate struct {
+		miss NearMiss
+		stub *compiledStub
+	}
+	var candidates []candidate
+
+	for mname, mn := range snap.methods {
+		methodCheck := checkTally{total: 1}
+		if mname != method {
+			methodCheck.reasons = []string{fmt.Sprintf("method expected %s got %s", mname, method)}
+		}
+		for _, un := range mn.urls {
+			urlCheck := methodCheck.plus(un.explain(req.URL))
+			for _, qn := range un.queries {
+				queryCheck := urlCheck.plus(qn.explain(req.Query))
+				for _, bn := range qn.bodies {
+					bodyCheck := queryCheck.plus(bn.explain(req.Body))
+					for _, cs := range bn.stubs {
+						c := bodyCheck.plus(cs.explainHeaders(req.Headers)).plus(cs.explainScenario(states))
+						if len(c.reasons) == 0 || len(c.reasons) == c.total {
+							// A match is not a near miss; neither is a stub that failed everything.
+							continue
+						}
+						candidates = append(candidates, candidate{
+							miss: NearMiss{
+								MappingID:   cs.mapping.ID,
+								Description: cs.mapping.Description,
+								Distance:    float64(len(c.reasons)) / float64(c.total),
+								Reasons:     c.reasons,
+							},
+							stub: cs,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.miss.Distance != b.miss.Distance {
+			return a.miss.Distance < b.miss.Distance
+		}
+		if a.stub.mapping.Priority != b.stub.mapping.Priority {
+			return a.stub.mapping.Priority < b.stub.mapping.Priority
+		}
+		return a.stub.order < b.stub.order
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	out := make([]NearMiss, len(candidates))
+	for i, c := range candidates {
+		out[i] = c.miss
+	}
+	return out
+}
+
+func (un *urlNode) explain(u string) checkTally {
+	if _, ok := un.matchURL(u); ok {
+		return checkTally{total: 1}
+	}
+	return checkTally{total: 1, reasons: []string{
+		fmt.Sprintf("url expected %s %s got %s", un.key.kind, un.key.pattern, u),
+	}}
+}
+
+func (qn *queryNode) explain(query map[string][]string) checkTally {
+	t := checkTally{total: len(qn.required)}
+	for _, km := range qn.required {
+		values, ok := query[km.Key]
+		if !km.matchValues(values, ok) {
+			t.reasons = append(t.reasons, fmt.Sprintf("query %s expected %s got %s", km.Key, describeValue(km.Expected), describeValues(values, ok)))
+		}
+	}
+	if qn.exact {
+		t.total++
+		if extra := qn.extraParams(query); len(extra) > 0 {
+			t.reasons = append(t.reasons, fmt.Sprintf("query expected no other params got %s", strings.Join(extra, ", ")))
+		}
+	}
+	return t
+}
+
+func (bn *bodyNode) explain(body any) checkTally {
+	t := checkTally{total: len(bn.matchers)}
+	if bn.equalJSON != nil {
+		t.total++
+		if !bn.equalJSON.match(body) {
+			t.reasons = append(t.reasons, "body does not equal bodyEqualToJson")
+		}
+	}
+	for _, m := range bn.matchers {
+		if m.match(body) {
+			continue
+		}
+		got, ok, _ := LookupBody(body, m.path)
+		t.reasons = append(t.reasons, fmt.Sprintf("body %s expected %s got %s", m.path, describeValue(m.expected), describeFound(got, ok)))
+	}
+	return t
+}
+
+func (cs *compiledStub) explainHeaders(headers map[string][]string) checkTally {
+	t := checkTally{total: len(cs.headerMatchers)}
+	for _, km := range cs.headerMatchers {
+		values, ok := headerValues(headers, km.Key)
+		if !km.matchValues(values, ok) {
+			t.reasons = append(t.reasons, fmt.Sprintf("header %s expected %s got %s", km.Key, describeValue(km.Expected), describeValues(values, ok)))
+		}
+	}
+	return t
+}
+
+func (cs *compiledStub) explainScenario(states map[string]string) checkTally {
+	m := cs.mapping
+	if m.ScenarioName == "" || m.RequiredScenarioState == "" {
+		return checkTally{}
+	}
+	t := checkTally{total: 1}
+	if !cs.matchesScenario(states) {
+		t.reasons = []string{fmt.Sprintf("scenario %s expected state %s got %s", m.ScenarioName, m.RequiredScenarioState, scenarioStateOf(states, m.ScenarioName))}
+	}
+	return t
+}
+
+// describeValue renders a configured matcher or a received value compactly:
+// strings as-is, everything else as JSON.
+func describeValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func describeValues(values []string, present bool) string {
+	if !present {
+		return "nothing"
+	}
+	return strings.Join(values, ",")
+}
+
+func describeFound(v any, ok bool) string {
+	if !ok {
+		return "nothing"
+	}
+	return describeValue(v)
+}
+
+// CallNearMisses pairs an unmatched call with the mappings that almost matched it.
+type CallNearMisses struct {
+	Call       CallRecord `json:"call"`
+	NearMisses []NearMiss `json:"nearMisses"`
+}
+
+// UnmatchedNearMisses explains every unmatched call in the history against
+// the current mappings, listing up to limit near misses per call.
+func UnmatchedNearMisses(limit int) []CallNearMisses {
+	out := []CallNearMisses{}
+	for _, rec := range GetCallHistory() {
+		if rec.MappingID != "" {
+			continue
+		}
+		out = append(out, CallNearMisses{
+			Call:       rec,
+			NearMisses: Global.NearMisses(rec.incoming(), limit),
+		})
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package appdata
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test that near misses are ranked by distance and name each failing field.
+func TestNearMisses(t *testing.T) {
+	ri := NewRuntimeIndex()
+	mappings := []Mapping{
+		{
+			ID:      "orders-by-user",
+			Request: Request{Method: "GET", URLPattern: "/orders", URLMatch: "exact", QueryParams: map[string]any{"userId": "123", "source": "mobile"}},
+		},
+		{
+			ID:      "orders-post",
+			Request: Request{Method: "POST", URLPattern: "/orders", URLMatch: "exact", Body: map[string]any{"customer.id": "c-1"}},
+		},
+		{
+			ID:      "users",
+			Request: Request{Method: "DELETE", URLPattern: "/users", URLMatch: "exact"},
+		},
+	}
+	for _, m := range mappings {
+		if err := ri.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", m.ID, err)
+		}
+	}
+
+	req := IncomingRequest{
+		Method: "GET",
+		URL:    "/orders",
+		Query:  map[string][]string{"userId": {"124"}, "source": {"mobile"}},
+	}
+	got := ri.NearMisses(req, 5)
+	if len(got) != 2 {
+		t.Fatalf("NearMisses = %+v, want 2 entries (the DELETE /users stub failed everything)", got)
+	}
+
+	if got[0].MappingID != "orders-by-user" || got[0].Distance != 0.25 {
+		t.Fatalf("closest = %+v, want orders-by-user at 0.25", got[0])
+	}
+	if want := []string{"query userId expected 123 got 124"}; !reflect.DeepEqual(got[0].Reasons, want) {
+		t.Fatalf("reasons = %q, want %q", got[0].Reasons, want)
+	}
+
+	wantPost := []string{"method expected POST got GET", "body customer.id expected c-1 got nothing"}
+	if got[1].MappingID != "orders-post" || !reflect.DeepEqual(got[1].Reasons, wantPost) {
+		t.Fatalf("second = %+v, want orders-post with %q", got[1], wantPost)
+	}
+
+	if top := ri.NearMisses(req, 1); len(top) != 1 || top[0].MappingID != "orders-by-user" {
+		t.Fatalf("NearMisses(limit 1) = %+v", top)
+	}
+}
+
+// Test that an exactQuery stub names the extra params of a near miss.
+func TestNearMissesExactQuery(t *testing.T) {
+	ri := NewRuntimeIndex()
+	if err := ri.Add(Mapping{
+		ID:      "search",
+		Request: Request{Method: "GET", URLPattern: "/search", URLMatch: "exact", QueryParams: map[string]any{"page": "1"}, ExactQuery: true},
+	}); err != nil {
+		t.Fatalf("Add(search) error = %v", err)
+	}
+
+	req := IncomingRequest{Method: "GET", URL: "/search", Query: map[string][]string{"page": {"1"}, "q": {"foo"}, "lang": {"en"}}}
+	got := ri.NearMisses(req, 1)
+	if want := []string{"query expected no other params got lang, q"}; len(got) != 1 || !reflect.DeepEqual(got[0].Reasons, want) {
+		t.Fatalf("NearMisses = %+v, want reasons %q", got, want)
+	}
+}
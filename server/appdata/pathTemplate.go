@@ -0,0 +1,69 @@
+package appdata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pathTemplate is a compiled urlMatch "template" pattern such as
+// "/users/{id}/orders/{orderId}". Each {name} must span a whole path segment
+// and captures exactly one non-empty segment of the request path.
+type pathTemplate struct {
+	segments []templateSegment
+	literals int // number of literal characters, used for specificity
+}
+
+type templateSegment struct {
+	literal string
+	param   string // non-empty for {name} segments
+}
+
+func compilePathTemplate(pattern string) (*pathTemplate, error) {
+	pt := &pathTemplate{}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name := strings.TrimSpace(part[1 : len(part)-1])
+			if name == "" {
+				return nil, fmt.Errorf("template %q: empty parameter name", pattern)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("template %q: duplicate parameter %q", pattern, name)
+			}
+			seen[name] = true
+			pt.segments = append(pt.segments, templateSegment{param: name})
+			continue
+		}
+		if strings.ContainsAny(part, "{}") {
+			return nil, fmt.Errorf("template %q: parameter must span a whole segment in %q", pattern, part)
+		}
+		pt.segments = append(pt.segments, templateSegment{literal: part})
+		pt.literals += len(part)
+	}
+	return pt, nil
+}
+
+// match returns the captured parameters when path fits the template.
+func (pt *pathTemplate) match(path string) (map[string]string, bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != len(pt.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, seg := range pt.segments {
+		if seg.param == "" {
+			if parts[i] != seg.literal {
+				return nil, false
+			}
+			continue
+		}
+		if parts[i] == "" {
+			return nil, false
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[seg.param] = parts[i]
+	}
+	return params, true
+}
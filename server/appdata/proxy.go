@@ -0,0 +1,64 @@
+package appdata
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// ProxyConfig is the fallback upstream: requests that match no mapping are
+// forwarded to BaseURL instead of getting the "no mock mapping found" 404.
+// A mapping can also forward on its own with response.proxyBaseUrl.
+//
+// RequestHeaders rewrites the forwarded request headers; an empty value
+// removes the header. A mapping's proxyRequestHeaders are applied after
+// these. TimeoutMs bounds the whole upstream exchange (0 means the default).
+type ProxyConfig struct {
+	BaseURL        string            `json:"baseUrl"`
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+	TimeoutMs      int               `json:"timeoutMs,omitempty"`
+}
+
+var (
+	proxyMu     sync.RWMutex
+	proxyConfig ProxyConfig
+)
+
+// SetProxyConfig validates and installs the fallback upstream. An empty
+// BaseURL turns the fallback off.
+func SetProxyConfig(c ProxyConfig) error {
+	if c.BaseURL != "" {
+		if err := validateProxyURL(c.BaseURL); err != nil {
+			return fmt.Errorf("baseUrl: %w", err)
+		}
+	}
+	if c.TimeoutMs < 0 {
+		return errors.New("timeoutMs must not be negative")
+	}
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	proxyConfig = c
+	return nil
+}
+
+// GetProxyConfig returns the fallback upstream configuration.
+func GetProxyConfig() ProxyConfig {
+	proxyMu.RLock()
+	defer proxyMu.RUnlock()
+	return proxyConfig
+}
+
+func validateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%q must be an absolute http(s) URL", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q must not have a query or fragment", raw)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package appdata
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// TransformTemplate enables request-driven templating of a response
+// ("transform": "template") status, headers, body and rawBody.
+// Templates use text/template syntax and see:
+//
+//	.Method .Path .PathParams .Query .Headers .Body
+//
+// plus helpers that return "" when a value is missing:
+//
+//	{{pathParam `id`}}  {{query `userId`}}  {{header `X-Tenant-Id`}}
+//	{{body `items[0].sku`}}  {{body `$.customer.email`}}
+const TransformTemplate = "template"
+
+// templateData is the value templates are executed against.
+type templateData struct {
+	Method     string
+	Path       string
+	PathParams map[string]string
+	Query      map[string][]string
+	Headers    map[string][]string
+	Body       any
+
+	paths *bodyPathCache
+}
+
+// responseTemplate holds a response's templates, parsed once at load time.
+type responseTemplate struct {
+	status  *requestTemplate
+	headers map[string]*requestTemplate
+	body    any // body tree with templated string leaves replaced by *requestTemplate
+	rawBody *requestTemplate
+	paths   bodyPathCache
+}
+
+func compileResponseTemplate(resp Response) (*responseTemplate, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Transform)) {
+	case "":
+		return nil, nil
+	case TransformTemplate:
+	default:
+		return nil, fmt.Errorf("unknown response.transform %q", resp.Transform)
+	}
+
+	rt := &responseTemplate{}
+	if resp.StatusTemplate != "" {
+		t, err := parseTemplate("statusTemplate", resp.StatusTemplate)
+		if err != nil {
+			return nil, err
+		}
+		rt.status = t
+	}
+	if len(resp.Headers) > 0 {
+		rt.headers = make(map[string]*requestTemplate, len(resp.Headers))
+		for k, v := range resp.Headers {
+			t, err := parseTemplate("headers."+k, v)
+			if err != nil {
+				return nil, err
+			}
+			rt.headers[k] = t
+		}
+	}
+	if resp.RawBody != "" {
+		t, err := parseTemplate("rawBody", resp.RawBody)
+		if err != nil {
+			return nil, err
+		}
+		rt.rawBody = t
+	}
+	body, err := compileBodyTemplate("body", resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	rt.body = body
+	return rt, nil
+}
+
+// compileBodyTemplate walks the JSON body at any depth and parses every
+// string leaf that contains an action.
+func compileBodyTemplate(name string, v any) (any, error) {
+	switch t := v.(type) {
+	case string:
+		if !strings.Contains(t, "{{") {
+			return t, nil
+		}
+		return parseTemplate(name, t)
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, child := range t {
+			c, err := compileBodyTemplate(name+"."+k, child)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = c
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			c, err := compileBodyTemplate(fmt.Sprintf("%s[%d]", name, i), child)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func parseTemplate(name, text string) (*requestTemplate, error) {
+	t, err := template.New(name).Option("missingkey=zero").Funcs(templateStubFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("response %s: %w", name, err)
+	}
+	return &requestTemplate{parsed: t}, nil
+}
+
+// templateStubFuncs declares the helper names for parsing; the request-bound
+// implementations are supplied by boundTemplate.
+var templateStubFuncs = template.FuncMap{
+	"pathParam": func(string) string { return "" },
+	"query":     func(string) string { return "" },
+	"header":    func(string) string { return "" },
+	"body":      func(string) (any, error) { return nil, nil },
+}
+
+// requestTemplate is a parsed template with a pool of clones whose helpers
+// are bound to the request being rendered, so a render reuses a clone
+// instead of cloning the template and its funcs every time.
+type requestTemplate struct {
+	parsed *template.Template
+	pool   sync.Pool // of *boundTemplate
+}
+
+// boundTemplate is a clone whose helper funcs read d, the request it is
+// currently rendering. A pooled clone serves one render at a time.
+type boundTemplate struct {
+	t *template.Template
+	d *templateData
+}
+
+func (rt *requestTemplate) execute(d *templateData) (string, error) {
+	bt, _ := rt.pool.Get().(*boundTemplate)
+	if bt == nil {
+		t, err := rt.parsed.Clone()
+		if err != nil {
+			return "", err
+		}
+		bt = &boundTemplate{}
+		bt.t = t.Funcs(bt.funcs())
+	}
+	bt.d = d
+	defer func() {
+		bt.d = nil
+		rt.pool.Put(bt)
+	}()
+
+	var b strings.Builder
+	if err := bt.t.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (bt *boundTemplate) funcs() template.FuncMap {
+	return template.FuncMap{
+		"pathParam": func(name string) string { return bt.d.PathParams[name] },
+		"query": func(name string) string {
+			if vs := bt.d.Query[name]; len(vs) > 0 {
+				return vs[0]
+			}
+			return ""
+		},
+		"header": func(name string) string {
+			if vs, ok := headerValues(bt.d.Headers, name); ok && len(vs) > 0 {
+				return vs[0]
+			}
+			return ""
+		},
+		"body": func(path string) (any, error) {
+			bp, err := bt.d.paths.get(path)
+			if err != nil {
+				return "", err
+			}
+			if v, ok := bp.lookup(bt.d.Body); ok {
+				return v, nil
+			}
+			return "", nil
+		},
+	}
+}
+
+// maxCachedBodyPaths bounds a template's path cache; paths built from
+// request data (e.g. with printf) would otherwise grow it without limit.
+const maxCachedBodyPaths = 64
+
+// bodyPathCache compiles each body helper path once per response template.
+type bodyPathCache struct {
+	mu    sync.Mutex
+	paths map[string]*bodyPath
+}
+
+func (c *bodyPathCache) get(path string) (*bodyPath, error) {
+	c.mu.Lock()
+	bp, ok := c.paths[path]
+	c.mu.Unlock()
+	if ok {
+		return bp, nil
+	}
+	bp, err := compileBodyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paths == nil {
+		c.paths = make(map[string]*bodyPath)
+	}
+	if len(c.paths) < maxCachedBodyPaths {
+		c.paths[path] = bp
+	}
+	return bp, nil
+}
+
+func (rt *responseTemplate) render(resp Response, d *templateData) (Response, error) {
+	d.paths = &rt.paths
+	out := resp
+	if rt.status != nil {
+		s, err := rt.status.execute(d)
+		if err != nil {
+			return Response{}, err
+		}
+		// net/http panics on codes outside 100-999; keep to the real ones.
+		code, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || code < 100 || code > 599 {
+			return Response{}, fmt.Errorf("response statusTemplate rendered %q: not a status code", s)
+		}
+		out.Status = code
+	}
+	if rt.headers != nil {
+		out.Headers = make(map[string]string, len(rt.headers))
+		keys := make([]string, 0, len(rt.headers))
+		for k := range rt.headers {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v, err := rt.headers[k].execute(d)
+			if err != nil {
+				return Response{}, err
+			}
+			out.Headers[k] = v
+		}
+	}
+	if rt.rawBody != nil {
+		raw, err := rt.rawBody.execute(d)
+		if err != nil {
+			return Response{}, err
+		}
+		out.RawBody = raw
+	}
+	body, err := renderBodyTemplate(rt.body, d)
+	if err != nil {
+		return Response{}, err
+	}
+	out.Body = body
+	return out, nil
+}
+
+func renderBodyTemplate(v any, d *templateData) (any, error) {
+	switch t := v.(type) {
+	case *requestTemplate:
+		return t.execute(d)
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, child := range t {
+			c, err := renderBodyTemplate(child, d)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = c
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			c, err := renderBodyTemplate(child, d)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+	return v, nil
+}
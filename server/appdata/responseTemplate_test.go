@@ -0,0 +1,190 @@
+package appdata
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test that templated status, headers and nested body leaves see request data.
+func TestMatchResultRenderTemplate(t *testing.T) {
+	ri := NewRuntimeIndex()
+	m := Mapping{
+		ID: "echo-order",
+		Request: Request{
+			Method:     "POST",
+			URLPattern: "/users/{id}/orders",
+			URLMatch:   "template",
+		},
+		Response: Response{
+			Transform:      "template",
+			StatusTemplate: "{{query `status`}}",
+			Headers:        map[string]string{"X-Tenant": "{{header `x-tenant-id`}}"},
+			Body: map[string]any{
+				"userId": "{{.PathParams.id}}",
+				"method": "{{.Method}} {{.Path}}",
+				"lines": []any{
+					map[string]any{"sku": "{{body `items[0].sku`}}", "fixed": float64(1)},
+				},
+				"email": "{{body `$.customer.email`}}",
+				"none":  "[{{query `missing`}}]",
+				"range": "{{range .Body.items}}{{.sku}}={{query `status`}};{{end}}",
+				"piped": "{{`id` | pathParam | printf `<%s>`}}",
+				"dyn":   "{{body (printf `items[%d].sku` 0)}}",
+			},
+		},
+	}
+	if err := ri.Add(m); err != nil {
+		t.Fatalf("Add(echo-order) error = %v", err)
+	}
+
+	req := IncomingRequest{
+		Method:  "post",
+		URL:     "/users/42/orders",
+		Query:   map[string][]string{"status": {"202"}},
+		Headers: map[string][]string{"X-Tenant-Id": {"acme"}},
+		Body: map[string]any{
+			"items":    []any{map[string]any{"sku": "A-1"}},
+			"customer": map[string]any{"email": "a@example.com"},
+		},
+	}
+	res, ok := ri.Match(req)
+	if !ok {
+		t.Fatalf("Match = no match, want echo-order")
+	}
+	got, err := res.Render(req)
+	if err != nil {
+		t.Fatalf("Render error = %v", err)
+	}
+
+	if got.Status != 202 {
+		t.Errorf("Status = %d, want 202", got.Status)
+	}
+	if got.Headers["X-Tenant"] != "acme" {
+		t.Errorf("Headers[X-Tenant] = %q, want acme", got.Headers["X-Tenant"])
+	}
+	want := map[string]any{
+		"userId": "42",
+		"method": "POST /users/42/orders",
+		"lines":  []any{map[string]any{"sku": "A-1", "fixed": float64(1)}},
+		"email":  "a@example.com",
+		"none":   "[]",
+		"range":  "A-1=202;",
+		"piped":  "<42>",
+		"dyn":    "A-1",
+	}
+	if !reflect.DeepEqual(got.Body, want) {
+		t.Errorf("Body = %#v, want %#v", got.Body, want)
+	}
+}
+
+// Test that template syntax errors are reported when the mapping is added.
+func TestRuntimeIndexAddRejectsBadTemplate(t *testing.T) {
+	ri := NewRuntimeIndex()
+	m := Mapping{
+		ID:       "bad-template",
+		Request:  Request{Method: "GET", URLPattern: "/x"},
+		Response: Response{Transform: "template", Body: map[string]any{"a": "{{.Path"}},
+	}
+	if err := ri.Add(m); err == nil {
+		t.Fatalf("Add(bad-template) error = nil, want parse error")
+	}
+}
+
+// Test that a statusTemplate without transform is rejected instead of being
+// silently ignored.
+func TestRuntimeIndexAddRejectsStatusTemplateWithoutTransform(t *testing.T) {
+	ri := NewRuntimeIndex()
+	m := Mapping{
+		ID:       "no-transform",
+		Request:  Request{Method: "GET", URLPattern: "/x"},
+		Response: Response{Status: 200, StatusTemplate: "{{query `status`}}"},
+	}
+	if err := ri.Add(m); err == nil || !strings.Contains(err.Error(), "statusTemplate requires transform") {
+		t.Fatalf("Add(no-transform) error = %v, want statusTemplate error", err)
+	}
+
+	resetGlobalMappings(t)
+	var verr *ValidationError
+	if _, err := CreateMapping(m); !errors.As(err, &verr) {
+		t.Fatalf("CreateMapping(no-transform) error = %v, want ValidationError", err)
+	}
+}
+
+// Test that an invalid body helper path fails the render.
+func TestRenderTemplateBadBodyPath(t *testing.T) {
+	ri := NewRuntimeIndex()
+	for _, path := range []string{"items[", "$.a["} {
+		if err := ri.Add(Mapping{
+			ID:       "bad-path",
+			Request:  Request{Method: "GET", URLPattern: "/x"},
+			Response: Response{Transform: "template", RawBody: "{{body `" + path + "`}}"},
+		}); err != nil {
+			t.Fatalf("Add(bad-path) error = %v", err)
+		}
+		req := IncomingRequest{Method: "GET", URL: "/x", Body: map[string]any{}}
+		res, ok := ri.Match(req)
+		if !ok {
+			t.Fatal("Match = no match, want bad-path")
+		}
+		if _, err := res.Render(req); err == nil {
+			t.Errorf("Render(body %q) error = nil, want path error", path)
+		}
+	}
+}
+
+// Test that a rendered status outside 100-599 fails the render.
+func TestRenderTemplateBadStatus(t *testing.T) {
+	ri := NewRuntimeIndex()
+	if err := ri.Add(Mapping{
+		ID:       "status",
+		Request:  Request{Method: "GET", URLPattern: "/x"},
+		Response: Response{Transform: "template", StatusTemplate: "{{query `status`}}"},
+	}); err != nil {
+		t.Fatalf("Add(status) error = %v", err)
+	}
+	for _, status := range []string{"42", "600", "5000", "abc"} {
+		req := IncomingRequest{Method: "GET", URL: "/x", Query: map[string][]string{"status": {status}}}
+		res, ok := ri.Match(req)
+		if !ok {
+			t.Fatal("Match = no match, want status")
+		}
+		if _, err := res.Render(req); err == nil {
+			t.Errorf("Render(status %q) error = nil, want status error", status)
+		}
+	}
+}
+
+// Test that one parsed template renders each request's own data when
+// requests are served concurrently.
+func TestRenderTemplateConcurrent(t *testing.T) {
+	ri := NewRuntimeIndex()
+	if err := ri.Add(Mapping{
+		ID:       "echo",
+		Request:  Request{Method: "GET", URLPattern: "/echo"},
+		Response: Response{Transform: "template", RawBody: "{{query `n`}}:{{body `n`}}"},
+	}); err != nil {
+		t.Fatalf("Add(echo) error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Go(func() {
+			n := strconv.Itoa(i)
+			req := IncomingRequest{Method: "GET", URL: "/echo", Query: map[string][]string{"n": {n}}, Body: map[string]any{"n": n}}
+			res, ok := ri.Match(req)
+			if !ok {
+				t.Error("Match = no match, want echo")
+				return
+			}
+			got, err := res.Render(req)
+			if err != nil || got.RawBody != n+":"+n {
+				t.Errorf("Render = %q, %v, want %s:%s", got.RawBody, err, n, n)
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,177 @@
+package appdata
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response modes for Mapping.Responses.
+//
+//   - sequence: serve the responses in order, then keep serving the last one
+//     ("fail twice, then succeed").
+//   - cycle: serve the responses in order and start over after the last one.
+//   - weightedRandom: pick a response at random, weighted by Response.Weight.
+//     Set Mapping.ResponseSeed to get the same picks on every run.
+const (
+	ResponseModeSequence       = "sequence"
+	ResponseModeCycle          = "cycle"
+	ResponseModeWeightedRandom = "weightedRandom"
+)
+
+// compileResponses validates the mapping's response (or responses list) and
+// parses their templates.
+func (cs *compiledStub) compileResponses() error {
+	m := cs.mapping
+	list := []Response{m.Response}
+	if len(m.Responses) > 0 {
+		if !reflect.ValueOf(m.Response).IsZero() {
+			return errors.New("only one of response, responses may be set")
+		}
+		list = m.Responses
+	} else if m.ResponseMode != "" {
+		return errors.New("responseMode requires responses")
+	}
+
+	switch m.ResponseMode {
+	case "", ResponseModeSequence, ResponseModeCycle, ResponseModeWeightedRandom:
+	default:
+		return fmt.Errorf("unknown responseMode %q", m.ResponseMode)
+	}
+
+	cs.responses = list
+	cs.responseTemplates = make([]*responseTemplate, len(list))
+	for i, resp := range list {
+		field := "response"
+		if len(m.Responses) > 0 {
+			field = fmt.Sprintf("responses[%d]", i)
+		}
+		if resp.Weight != nil && *resp.Weight < 0 {
+			return fmt.Errorf("%s.weight must not be negative", field)
+		}
+		if resp.StatusTemplate != "" && strings.TrimSpace(resp.Transform) == "" {
+			return fmt.Errorf("%s.statusTemplate requires transform", field)
+		}
+		if err := validateResponseBody(resp); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		if err := validateFault(resp.Fault); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		if err := validateDelay(resp); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		rt, err := compileResponseTemplate(resp)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		cs.responseTemplates[i] = rt
+	}
+	if m.ResponseMode == ResponseModeWeightedRandom && totalWeight(list) == 0 {
+		return errors.New("weightedRandom needs at least one response with a non-zero weight")
+	}
+	return nil
+}
+
+// responseStore keeps the per-mapping position in a responses list. Like
+// scenario states it lives on the RuntimeIndex, so reloads and admin changes
+// to other mappings do not restart a sequence.
+type responseStore struct {
+	mu     sync.Mutex
+	states map[string]*responseState
+}
+
+type responseState struct {
+	served int // responses served so far
+	rng    *rand.Rand
+}
+
+// pick returns the index of the response cs should serve next.
+func (s *responseStore) pick(cs *compiledStub) int {
+	n := len(cs.responses)
+	if n <= 1 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]*responseState)
+	}
+	key := cs.mapping.ID
+	st := s.states[key]
+	if st == nil {
+		st = &responseState{}
+		s.states[key] = st
+	}
+
+	var i int
+	switch cs.mapping.ResponseMode {
+	case ResponseModeCycle:
+		i = st.served % n
+	case ResponseModeWeightedRandom:
+		if st.rng == nil {
+			seed := time.Now().UnixNano()
+			if cs.mapping.ResponseSeed != nil {
+				seed = *cs.mapping.ResponseSeed
+			}
+			st.rng = rand.New(rand.NewSource(seed))
+		}
+		i = weightedPick(cs.responses, st.rng)
+	default:
+		i = min(st.served, n-1)
+	}
+	st.served++
+	return i
+}
+
+// weightedPick chooses an index with probability proportional to its weight.
+func weightedPick(responses []Response, rng *rand.Rand) int {
+	total := totalWeight(responses)
+	if total == 0 {
+		return 0
+	}
+	n := rng.Intn(total)
+	for i, r := range responses {
+		n -= responseWeight(r)
+		if n < 0 {
+			return i
+		}
+	}
+	return len(responses) - 1
+}
+
+func totalWeight(responses []Response) int {
+	total := 0
+	for _, r := range responses {
+		total += responseWeight(r)
+	}
+	return total
+}
+
+// responseWeight is r's weight, 1 when unset.
+func responseWeight(r Response) int {
+	if r.Weight == nil {
+		return 1
+	}
+	return *r.Weight
+}
+
+// ResetResponses restarts the responses list of every mapping (and re-seeds
+// weightedRandom picks).
+func (ri *RuntimeIndex) ResetResponses() {
+	ri.responses.mu.Lock()
+	defer ri.responses.mu.Unlock()
+	ri.responses.states = nil
+}
+
+// ResetMappingResponses restarts the responses list of one mapping.
+func (ri *RuntimeIndex) ResetMappingResponses(id string) {
+	ri.responses.mu.Lock()
+	defer ri.responses.mu.Unlock()
+	delete(ri.responses.states, id)
+}
@@ -0,0 +1,218 @@
+package appdata
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func responsesMapping(mode string, seed *int64, statuses ...int) Mapping {
+	m := Mapping{
+		ID:           "retry",
+		Request:      Request{Method: "GET", URLPattern: "/flaky", URLMatch: "exact"},
+		ResponseMode: mode,
+		ResponseSeed: seed,
+	}
+	for _, s := range statuses {
+		m.Responses = append(m.Responses, Response{Status: s})
+	}
+	return m
+}
+
+func renderStatuses(t *testing.T, ri *RuntimeIndex, n int) []int {
+	t.Helper()
+	req := IncomingRequest{Method: "GET", URL: "/flaky"}
+	var out []int
+	for i := 0; i < n; i++ {
+		res, ok := ri.Match(req)
+		if !ok {
+			t.Fatalf("Match #%d: no match", i)
+		}
+		resp, err := res.Render(req)
+		if err != nil {
+			t.Fatalf("Render #%d error = %v", i, err)
+		}
+		out = append(out, resp.Status)
+	}
+	return out
+}
+
+// Test that sequence sticks on the last response and cycle wraps around,
+// and that resetting restarts the list.
+func TestResponsesSequenceAndCycle(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []int
+	}{
+		{"", []int{503, 503, 200, 200, 200}},
+		{ResponseModeSequence, []int{503, 503, 200, 200, 200}},
+		{ResponseModeCycle, []int{503, 503, 200, 503, 503}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			ri := NewRuntimeIndex()
+			if err := ri.Add(responsesMapping(tt.mode, nil, 503, 503, 200)); err != nil {
+				t.Fatalf("Add error = %v", err)
+			}
+			// Lookups do not advance the list.
+			if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: "/flaky"}); !ok {
+				t.Fatal("FindBestMatch: no match")
+			}
+			if got := renderStatuses(t, ri, 5); !slices.Equal(got, tt.want) {
+				t.Fatalf("statuses = %v, want %v", got, tt.want)
+			}
+
+			ri.ResetMappingResponses("retry")
+			if got := renderStatuses(t, ri, 1); got[0] != 503 {
+				t.Fatalf("after reset status = %d, want 503", got[0])
+			}
+		})
+	}
+}
+
+// Test that weightedRandom is reproducible with a seed and honours weights.
+func TestResponsesWeightedRandom(t *testing.T) {
+	seed := int64(42)
+	m := responsesMapping(ResponseModeWeightedRandom, &seed, 200, 500)
+	m.Responses[0].Weight = intPtr(3)
+	m.Responses[1].Weight = intPtr(1)
+
+	ri := NewRuntimeIndex()
+	if err := ri.Add(m); err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	first := renderStatuses(t, ri, 400)
+	ri.ResetResponses()
+	if again := renderStatuses(t, ri, 400); !slices.Equal(first, again) {
+		t.Fatalf("seeded picks differ after reset")
+	}
+
+	ok := 0
+	for _, s := range first {
+		if s == 200 {
+			ok++
+		}
+	}
+	if ok < 250 || ok > 350 {
+		t.Fatalf("200 picked %d/400 times, want about 300", ok)
+	}
+}
+
+// Test that a zero weight turns an entry off while an unset weight counts
+// as 1.
+func TestResponsesWeightedRandomZeroWeight(t *testing.T) {
+	m := responsesMapping(ResponseModeWeightedRandom, nil, 200, 500, 503)
+	m.Responses[1].Weight = intPtr(0)
+
+	ri := NewRuntimeIndex()
+	if err := ri.Add(m); err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	seen := map[int]int{}
+	for _, s := range renderStatuses(t, ri, 200) {
+		seen[s]++
+	}
+	if seen[500] != 0 || seen[200] == 0 || seen[503] == 0 {
+		t.Fatalf("picked %v, want 200 and 503 but never 500", seen)
+	}
+}
+
+// Test that concurrent requests each consume exactly one sequence entry.
+func TestResponsesConcurrentSequence(t *testing.T) {
+	ri := NewRuntimeIndex()
+	if err := ri.Add(responsesMapping(ResponseModeSequence, nil, 500, 501, 502, 200)); err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		counts = map[int]int{}
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := ri.Match(IncomingRequest{Method: "GET", URL: "/flaky"})
+			resp, _ := res.Render(IncomingRequest{})
+			mu.Lock()
+			counts[resp.Status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if counts[500] != 1 || counts[501] != 1 || counts[502] != 1 || counts[200] != 17 {
+		t.Fatalf("status counts = %v", counts)
+	}
+}
+
+func TestResponsesValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Mapping
+	}{
+		{"response and responses", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Response = Response{Status: 201}
+			return m
+		}()},
+		{"unknown mode", responsesMapping("random", nil, 200)},
+		{"mode without responses", Mapping{
+			ID:           "x",
+			Request:      Request{Method: "GET", URLPattern: "/x"},
+			ResponseMode: ResponseModeCycle,
+		}},
+		{"negative weight", func() Mapping {
+			m := responsesMapping(ResponseModeWeightedRandom, nil, 200)
+			m.Responses[0].Weight = intPtr(-1)
+			return m
+		}()},
+		{"all weights zero", func() Mapping {
+			m := responsesMapping(ResponseModeWeightedRandom, nil, 200, 500)
+			m.Responses[0].Weight = intPtr(0)
+			m.Responses[1].Weight = intPtr(0)
+			return m
+		}()},
+		{"unknown fault", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Responses[0].Fault = "SLOW_LORIS"
+			return m
+		}()},
+		{"relative proxy url", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Responses[0].ProxyBaseURL = "/upstream"
+			return m
+		}()},
+		{"proxy with body", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Responses[0].ProxyBaseURL = "http://upstream"
+			m.Responses[0].RawBody = "x"
+			return m
+		}()},
+		{"proxy with fault", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Responses[0].ProxyBaseURL = "http://upstream"
+			m.Responses[0].Fault = "EMPTY_RESPONSE"
+			return m
+		}()},
+		{"invalid entry body", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Responses[0].Base64Body = "!!"
+			return m
+		}()},
+		{"header in both header maps", func() Mapping {
+			m := responsesMapping("", nil, 200)
+			m.Responses[0].Headers = map[string]string{"Set-Cookie": "a=1"}
+			m.Responses[0].MultiValueHeaders = map[string][]string{"set-cookie": {"b=2", "c=3"}}
+			return m
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewRuntimeIndex().Add(tt.m); err == nil {
+				t.Fatalf("Add succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package appdata
+
+import (
+	"sort"
+	"sync"
+)
+
+// ScenarioStarted is the state every scenario begins in (and returns to on reset).
+const ScenarioStarted = "Started"
+
+// scenarioStore holds the current state of each scenario. It lives on the
+// RuntimeIndex rather than in a snapshot, so states survive reloads.
+type scenarioStore struct {
+	mu     sync.Mutex
+	states map[string]string
+}
+
+func (s *scenarioStore) get(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stateLocked(name)
+}
+
+func (s *scenarioStore) stateLocked(name string) string {
+	if st, ok := s.states[name]; ok {
+		return st
+	}
+	return ScenarioStarted
+}
+
+// copyStates returns the current states for a consistent matching pass.
+func (s *scenarioStore) copyStates() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]string, len(s.states))
+	for k, v := range s.states {
+		out[k] = v
+	}
+	return out
+}
+
+// transition moves name from expected to next; it fails if another request
+// changed the state since it was observed.
+func (s *scenarioStore) transition(name, expected, next string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stateLocked(name) != expected {
+		return false
+	}
+	if s.states == nil {
+		s.states = make(map[string]string)
+	}
+	s.states[name] = next
+	return true
+}
+
+func (s *scenarioStore) set(name, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]string)
+	}
+	s.states[name] = state
+}
+
+func (s *scenarioStore) reset(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, name)
+}
+
+func (s *scenarioStore) resetAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = nil
+}
+
+func scenarioStateOf(states map[string]string, name string) string {
+	if st, ok := states[name]; ok {
+		return st
+	}
+	return ScenarioStarted
+}
+
+// ScenarioInfo describes a scenario for admin APIs.
+type ScenarioInfo struct {
+	Name           string   `json:"name"`
+	State          string   `json:"state"`
+	PossibleStates []string `json:"possibleStates"`
+	MappingIDs     []string `json:"mappingIds"`
+}
+
+// Scenarios lists every scenario referenced by a mapping with its current state.
+func (ri *RuntimeIndex) Scenarios() []ScenarioInfo {
+	byName := make(map[string]*ScenarioInfo)
+	possible := make(map[string]map[string]bool)
+	for _, m := range ri.snapshot().mappings {
+		if m.ScenarioName == "" {
+			continue
+		}
+		info := byName[m.ScenarioName]
+		if info == nil {
+			info = &ScenarioInfo{Name: m.ScenarioName}
+			byName[m.ScenarioName] = info
+			possible[m.ScenarioName] = map[string]bool{ScenarioStarted: true}
+		}
+		info.MappingIDs = append(info.MappingIDs, m.ID)
+		for _, st := range []string{m.RequiredScenarioState, m.NewScenarioState} {
+			if st != "" {
+				possible[m.ScenarioName][st] = true
+			}
+		}
+	}
+
+	out := make([]ScenarioInfo, 0, len(byName))
+	for name, info := range byName {
+		info.State = ri.scenarios.get(name)
+		for st := range possible[name] {
+			info.PossibleStates = append(info.PossibleStates, st)
+		}
+		sort.Strings(info.PossibleStates)
+		out = append(out, *info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// HasScenario reports whether any mapping references the named scenario.
+func (ri *RuntimeIndex) HasScenario(name string) bool {
+	for _, m := range ri.snapshot().mappings {
+		if m.ScenarioName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetScenarios returns every scenario to ScenarioStarted.
+func (ri *RuntimeIndex) ResetScenarios() {
+	ri.scenarios.resetAll()
+}
+
+// ResetScenario returns one scenario to ScenarioStarted.
+func (ri *RuntimeIndex) ResetScenario(name string) {
+	ri.scenarios.reset(name)
+}
+
+// SetScenarioState forces a scenario into the given state.
+func (ri *RuntimeIndex) SetScenarioState(name, state string) {
+	ri.scenarios.set(name, state)
+}
@@ -0,0 +1,68 @@
+package appdata
+
+import "testing"
+
+// Test the "PENDING until approved" flow: GET returns PENDING, POST /approve
+// moves the scenario, then GET returns APPROVED until the scenario is reset.
+func TestScenarioTransitions(t *testing.T) {
+	ri := NewRuntimeIndex()
+	mappings := []Mapping{
+		{
+			ID:                    "pending",
+			Request:               Request{Method: "GET", URLPattern: "/order/1", URLMatch: "exact"},
+			Response:              Response{Body: "PENDING"},
+			ScenarioName:          "approval",
+			RequiredScenarioState: ScenarioStarted,
+		},
+		{
+			ID:               "approve",
+			Request:          Request{Method: "POST", URLPattern: "/order/1/approve", URLMatch: "exact"},
+			ScenarioName:     "approval",
+			NewScenarioState: "APPROVED",
+		},
+		{
+			ID:                    "approved",
+			Request:               Request{Method: "GET", URLPattern: "/order/1", URLMatch: "exact"},
+			Response:              Response{Body: "APPROVED"},
+			ScenarioName:          "approval",
+			RequiredScenarioState: "APPROVED",
+		},
+	}
+	for _, m := range mappings {
+		if err := ri.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", m.ID, err)
+		}
+	}
+
+	get := IncomingRequest{Method: "GET", URL: "/order/1"}
+	expectID := func(want string) {
+		t.Helper()
+		got, ok := ri.Match(get)
+		if !ok || got.Mapping.ID != want {
+			t.Fatalf("Match(GET) = %q, %v, want %q", got.Mapping.ID, ok, want)
+		}
+	}
+
+	expectID("pending")
+	expectID("pending")
+
+	approve := IncomingRequest{Method: "POST", URL: "/order/1/approve"}
+	// Looking up the transition stub does not apply it.
+	if got, ok := ri.FindBestMatch(approve); !ok || got.ID != "approve" {
+		t.Fatalf("FindBestMatch(approve) = %q, %v", got.ID, ok)
+	}
+	expectID("pending")
+
+	if got, ok := ri.Match(approve); !ok || got.Mapping.ID != "approve" {
+		t.Fatalf("Match(approve) = %q, %v", got.Mapping.ID, ok)
+	}
+	expectID("approved")
+
+	infos := ri.Scenarios()
+	if len(infos) != 1 || infos[0].State != "APPROVED" || len(infos[0].MappingIDs) != 3 {
+		t.Fatalf("Scenarios() = %+v, want approval in APPROVED with 3 mappings", infos)
+	}
+
+	ri.ResetScenarios()
+	expectID("pending")
+}
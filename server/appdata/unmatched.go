@@ -0,0 +1,168 @@
+package appdata
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnmatchedNotFound is returned when no unmatched call has the requested
+// method and path signature.
+var ErrUnmatchedNotFound = errors.New("no unmatched calls")
+
+// maxUnmatchedExamples caps the distinct URLs kept per unmatched group.
+const maxUnmatchedExamples = 5
+
+// UnmatchedGroup summarizes unmatched calls sharing a method and path
+// signature (see PathSignature).
+type UnmatchedGroup struct {
+	Method        string     `json:"method"`
+	PathSignature string     `json:"pathSignature"`
+	Count         int        `json:"count"`
+	FirstSeen     time.Time  `json:"firstSeen"`
+	LastSeen      time.Time  `json:"lastSeen"`
+	Examples      []string   `json:"examples"`
+	LastCall      CallRecord `json:"lastCall"`
+}
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{12,}$`)
+)
+
+// pathLiteralEscaper percent-escapes literal segments of a signature, so a
+// literal "{id}" in a path is not mistaken for a parameter.
+var (
+	pathLiteralEscaper   = strings.NewReplacer("%", "%25", "{", "%7B", "}", "%7D")
+	pathLiteralUnescaper = strings.NewReplacer("%25", "%", "%7B", "{", "%7D", "}")
+)
+
+// PathSignature replaces id-like path segments (numbers, UUIDs, long hex
+// strings) with template parameters, so "/users/42/orders/7" becomes
+// "/users/{id}/orders/{id2}". Braces and '%' in the other segments are
+// percent-escaped; without them the result is a valid "template" urlPattern.
+func PathSignature(path string) string {
+	parts := strings.Split(path, "/")
+	n := 0
+	for i, p := range parts {
+		if !isIDSegment(p) {
+			parts[i] = pathLiteralEscaper.Replace(p)
+			continue
+		}
+		n++
+		parts[i] = "{id}"
+		if n > 1 {
+			parts[i] = "{id" + strconv.Itoa(n) + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func isIDSegment(s string) bool {
+	if s == "" {
+		return false
+	}
+	if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return true
+	}
+	return uuidSegment.MatchString(s) || hexSegment.MatchString(s) && strings.ContainsAny(s, "0123456789")
+}
+
+// UnmatchedCalls groups the unmatched calls in the history (including those
+// forwarded to the fallback upstream), most frequent first.
+func UnmatchedCalls() []UnmatchedGroup {
+	groups := make(map[string]*UnmatchedGroup)
+	for _, rec := range GetCallHistory() {
+		if rec.MappingID != "" {
+			continue
+		}
+		method := strings.ToUpper(rec.Method)
+		sig := PathSignature(rec.URL)
+		key := method + " " + sig
+		g := groups[key]
+		if g == nil {
+			g = &UnmatchedGroup{Method: method, PathSignature: sig, FirstSeen: rec.Time, Examples: []string{}}
+			groups[key] = g
+		}
+		g.Count++
+		g.LastSeen = rec.Time
+		g.LastCall = rec
+		if len(g.Examples) < maxUnmatchedExamples && !slices.Contains(g.Examples, rec.URL) {
+			g.Examples = append(g.Examples, rec.URL)
+		}
+	}
+
+	out := make([]UnmatchedGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if out[i].Method != out[j].Method {
+			return out[i].Method < out[j].Method
+		}
+		return out[i].PathSignature < out[j].PathSignature
+	})
+	return out
+}
+
+// DraftMapping turns a group of unmatched calls into a draft mapping that
+// would match all of them. The response is a placeholder to fill in.
+// pathSignature may also be a concrete path from the group.
+func DraftMapping(method, pathSignature string) (Mapping, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	concrete := PathSignature(pathSignature)
+	for _, g := range UnmatchedCalls() {
+		if g.Method != method || g.PathSignature != pathSignature && g.PathSignature != concrete {
+			continue
+		}
+		pattern, match := draftURLPattern(g.PathSignature)
+		return Mapping{
+			ID:          newMappingID(),
+			Description: fmt.Sprintf("Draft for %d unmatched %s %s calls", g.Count, method, g.PathSignature),
+			Request: Request{
+				Method:     method,
+				URLPattern: pattern,
+				URLMatch:   match,
+			},
+			Response: Response{
+				Status: 200,
+				Body:   map[string]any{},
+			},
+			Metadata: Metadata{Tags: []string{"draft"}},
+		}, nil
+	}
+	return Mapping{}, fmt.Errorf("%w for %s %s", ErrUnmatchedNotFound, method, concrete)
+}
+
+// draftURLPattern turns a signature into an urlPattern: an exact path when
+// it has no parameters, a template when its literals need no escaping, and
+// an anchored regex otherwise.
+func draftURLPattern(sig string) (pattern, match string) {
+	parts := strings.Split(sig, "/")
+	hasParam, escaped := false, false
+	for i, p := range parts {
+		if strings.HasPrefix(p, "{") {
+			hasParam = true
+			parts[i] = "[^/]+"
+			continue
+		}
+		literal := pathLiteralUnescaper.Replace(p)
+		escaped = escaped || literal != p
+		parts[i] = regexp.QuoteMeta(literal)
+	}
+	switch {
+	case !hasParam:
+		return pathLiteralUnescaper.Replace(sig), "exact"
+	case !escaped:
+		return sig, "template"
+	}
+	return "^" + strings.Join(parts, "/") + "$", "regex"
+}
@@ -0,0 +1,115 @@
+package appdata
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPathSignature(t *testing.T) {
+	tests := map[string]string{
+		"/users/42/orders/7":                           "/users/{id}/orders/{id2}",
+		"/orders/3f2b7c1e-9a4d-4c5e-8b6f-0123456789ab": "/orders/{id}",
+		"/blobs/a1b2c3d4e5f6a7b8":                      "/blobs/{id}",
+		"/health":                                      "/health",
+		"/users/me":                                    "/users/me",
+		"/pages/deadbeefcafe":                          "/pages/deadbeefcafe",
+		"/files/{id}/7":                                "/files/%7Bid%7D/{id}",
+		"/odd/50%":                                     "/odd/50%25",
+	}
+	for in, want := range tests {
+		if got := PathSignature(in); got != want {
+			t.Errorf("PathSignature(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// Test that unmatched calls are grouped by method and path signature, and
+// that a draft mapping matches every call of its group.
+func TestUnmatchedCallsAndDraft(t *testing.T) {
+	ResetCallHistory()
+	t.Cleanup(ResetCallHistory)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	RecordCall(CallRecord{Time: base, Method: "GET", URL: "/users/1", Status: 404})
+	RecordCall(CallRecord{Time: base.Add(time.Second), Method: "GET", URL: "/users/2", Status: 404})
+	RecordCall(CallRecord{Time: base.Add(2 * time.Second), Method: "GET", URL: "/users/1", Status: 404})
+	RecordCall(CallRecord{Time: base.Add(3 * time.Second), Method: "POST", URL: "/users", Status: 404})
+	RecordCall(CallRecord{Time: base.Add(4 * time.Second), Method: "GET", URL: "/users/3", MappingID: "matched", Status: 200})
+
+	groups := UnmatchedCalls()
+	if len(groups) != 2 {
+		t.Fatalf("UnmatchedCalls = %+v, want 2 groups", groups)
+	}
+	g := groups[0]
+	if g.Method != "GET" || g.PathSignature != "/users/{id}" || g.Count != 3 || len(g.Examples) != 2 {
+		t.Fatalf("first group = %+v", g)
+	}
+	if !g.FirstSeen.Equal(base) || !g.LastSeen.Equal(base.Add(2*time.Second)) || g.LastCall.URL != "/users/1" {
+		t.Fatalf("first group times = %v..%v last %q", g.FirstSeen, g.LastSeen, g.LastCall.URL)
+	}
+
+	draft, err := DraftMapping("get", "/users/2")
+	if err != nil {
+		t.Fatalf("DraftMapping error = %v", err)
+	}
+	ri := NewRuntimeIndex()
+	if err := ri.Add(draft); err != nil {
+		t.Fatalf("Add(draft) error = %v", err)
+	}
+	for _, u := range g.Examples {
+		if _, ok := ri.Match(IncomingRequest{Method: "GET", URL: u}); !ok {
+			t.Errorf("draft does not match %s", u)
+		}
+	}
+
+	if _, err := DraftMapping("DELETE", "/users/1"); !errors.Is(err, ErrUnmatchedNotFound) {
+		t.Fatalf("DraftMapping(unknown) error = %v, want ErrUnmatchedNotFound", err)
+	}
+}
+
+// Test that literal braces in unmatched paths give drafts that compile and
+// match only their own paths.
+func TestDraftMappingLiteralBraces(t *testing.T) {
+	ResetCallHistory()
+	t.Cleanup(ResetCallHistory)
+	for _, u := range []string{"/files/{id}/7", "/files/{id}/8", "/tpl/{x}"} {
+		RecordCall(CallRecord{Method: "GET", URL: u, Status: 404})
+	}
+
+	tests := []struct {
+		sig       string
+		wantMatch string
+		matches   []string
+		misses    []string
+	}{
+		{"/files/%7Bid%7D/{id}", "regex", []string{"/files/{id}/7", "/files/{id}/9"}, []string{"/files/x/7"}},
+		{"/tpl/{x}", "exact", []string{"/tpl/{x}"}, []string{"/tpl/y"}},
+	}
+	for _, tt := range tests {
+		draft, err := DraftMapping("GET", tt.matches[0])
+		if err != nil {
+			t.Fatalf("DraftMapping(%s) error = %v", tt.matches[0], err)
+		}
+		if draft.Request.URLMatch != tt.wantMatch {
+			t.Errorf("draft for %s urlMatch = %q (%s), want %q", tt.sig, draft.Request.URLMatch, draft.Request.URLPattern, tt.wantMatch)
+		}
+		if _, err := DraftMapping("GET", tt.sig); err != nil {
+			t.Errorf("DraftMapping(%s) by signature error = %v", tt.sig, err)
+		}
+		ri := NewRuntimeIndex()
+		if err := ri.Add(draft); err != nil {
+			t.Fatalf("Add(draft %s) error = %v", draft.Request.URLPattern, err)
+		}
+		for _, u := range tt.matches {
+			if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: u}); !ok {
+				t.Errorf("draft %s does not match %s", draft.Request.URLPattern, u)
+			}
+		}
+		for _, u := range tt.misses {
+			if _, ok := ri.FindBestMatch(IncomingRequest{Method: "GET", URL: u}); ok {
+				t.Errorf("draft %s matches %s", draft.Request.URLPattern, u)
+			}
+		}
+	}
+}
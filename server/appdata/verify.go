@@ -0,0 +1,87 @@
+package appdata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Verification asserts how many recorded calls match a request pattern, e.g.
+//
+//	{"request": {"method": "POST", "urlPattern": "/orders", "urlMatch": "exact",
+//	             "body": {"customer.id": "c-1"}},
+//	 "exactly": 1}
+//
+// The pattern uses the same fields and operators as a mapping's request; an
+// empty method or urlPattern matches anything. Without a count expectation
+// the verification passes when at least one call matched. Headers redacted
+// in history (HistoryConfig.RedactHeaders) cannot be matched.
+type Verification struct {
+	Request Request `json:"request"`
+	Exactly *int    `json:"exactly,omitempty"`
+	AtLeast *int    `json:"atLeast,omitempty"`
+	AtMost  *int    `json:"atMost,omitempty"`
+}
+
+// VerificationResult is the outcome of Verify.
+type VerificationResult struct {
+	Passed   bool         `json:"passed"`
+	Count    int          `json:"count"`
+	Expected string       `json:"expected"`
+	Calls    []CallRecord `json:"calls"`
+}
+
+// Verify checks v against the call history.
+func Verify(v Verification) (VerificationResult, error) {
+	if v.Exactly != nil && (v.AtLeast != nil || v.AtMost != nil) {
+		return VerificationResult{}, errors.New("exactly cannot be combined with atLeast or atMost")
+	}
+	for _, n := range []*int{v.Exactly, v.AtLeast, v.AtMost} {
+		if n != nil && *n < 0 {
+			return VerificationResult{}, errors.New("counts must not be negative")
+		}
+	}
+
+	for name := range v.Request.Headers {
+		if isRedacted(name) {
+			return VerificationResult{}, fmt.Errorf("request.headers.%s: header values are redacted in call history", name)
+		}
+	}
+
+	pattern := Mapping{ID: "verify", Request: v.Request}
+	pattern.Request.Method = strings.ToUpper(strings.TrimSpace(v.Request.Method))
+	cs, err := compileStub(pattern, 0)
+	if err != nil {
+		return VerificationResult{}, err
+	}
+
+	res := VerificationResult{Calls: []CallRecord{}}
+	for _, rec := range GetCallHistory() {
+		if cs.matches(rec.incoming()) {
+			res.Calls = append(res.Calls, rec)
+		}
+	}
+	res.Count = len(res.Calls)
+
+	switch {
+	case v.Exactly != nil:
+		res.Expected = fmt.Sprintf("exactly %d", *v.Exactly)
+		res.Passed = res.Count == *v.Exactly
+	case v.AtLeast != nil || v.AtMost != nil:
+		res.Passed = true
+		var parts []string
+		if v.AtLeast != nil {
+			parts = append(parts, fmt.Sprintf("at least %d", *v.AtLeast))
+			res.Passed = res.Count >= *v.AtLeast
+		}
+		if v.AtMost != nil {
+			parts = append(parts, fmt.Sprintf("at most %d", *v.AtMost))
+			res.Passed = res.Passed && res.Count <= *v.AtMost
+		}
+		res.Expected = strings.Join(parts, " and ")
+	default:
+		res.Expected = "at least 1"
+		res.Passed = res.Count >= 1
+	}
+	return res, nil
+}
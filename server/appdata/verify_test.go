@@ -0,0 +1,57 @@
+package appdata
+
+import "testing"
+
+func intPtr(n int) *int { return &n }
+
+// Test count expectations against recorded calls using mapping-style patterns.
+func TestVerify(t *testing.T) {
+	ResetCallHistory()
+	t.Cleanup(ResetCallHistory)
+
+	RecordCall(CallRecord{Method: "POST", URL: "/orders", RequestBody: map[string]any{"customer": map[string]any{"id": "c-1"}}, MappingID: "create"})
+	RecordCall(CallRecord{Method: "POST", URL: "/orders", RequestBody: map[string]any{"customer": map[string]any{"id": "c-2"}}, MappingID: "create"})
+	RecordCall(CallRecord{Method: "GET", URL: "/orders/7", Query: map[string][]string{"expand": {"items"}}, RequestHeaders: map[string][]string{"X-Tenant": {"acme"}}})
+
+	tests := []struct {
+		name      string
+		v         Verification
+		wantCount int
+		wantPass  bool
+	}{
+		{"exact url and method", Verification{Request: Request{Method: "post", URLPattern: "/orders", URLMatch: "exact"}, Exactly: intPtr(2)}, 2, true},
+		{"body field", Verification{Request: Request{Method: "POST", URLPattern: "/orders", Body: map[string]any{"customer.id": "c-1"}}, Exactly: intPtr(1)}, 1, true},
+		{"any method template and query", Verification{Request: Request{URLPattern: "/orders/{id}", URLMatch: "template", QueryParams: map[string]any{"expand": "items"}}}, 1, true},
+		{"default needs one", Verification{Request: Request{Method: "DELETE"}}, 0, false},
+		{"at most", Verification{Request: Request{URLPattern: "/orders"}, AtMost: intPtr(2)}, 3, false},
+		{"range", Verification{Request: Request{URLPattern: "/orders"}, AtLeast: intPtr(1), AtMost: intPtr(3)}, 3, true},
+		{"never called", Verification{Request: Request{URLPattern: "/payments"}, Exactly: intPtr(0)}, 0, true},
+		{"header", Verification{Request: Request{Headers: map[string]any{"x-tenant": "acme"}}, Exactly: intPtr(1)}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Verify(tt.v)
+			if err != nil {
+				t.Fatalf("Verify error = %v", err)
+			}
+			if res.Count != tt.wantCount || res.Passed != tt.wantPass || len(res.Calls) != tt.wantCount {
+				t.Fatalf("Verify = count %d passed %v (%s), want count %d passed %v", res.Count, res.Passed, res.Expected, tt.wantCount, tt.wantPass)
+			}
+		})
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	bad := []Verification{
+		{Exactly: intPtr(1), AtLeast: intPtr(1)},
+		{AtLeast: intPtr(-1)},
+		{Request: Request{URLPattern: "(", URLMatch: "regex"}},
+		{Request: Request{QueryParams: map[string]any{"a": map[string]any{"matches": "("}}}},
+		{Request: Request{Headers: map[string]any{"authorization": "Bearer t"}}},
+	}
+	for _, v := range bad {
+		if _, err := Verify(v); err == nil {
+			t.Errorf("Verify(%+v) succeeded, want error", v)
+		}
+	}
+}
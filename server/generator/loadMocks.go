@@ -10,8 +10,22 @@ import (
 
 type Mocks = map[string]any
 
-func loadMocks(dir string) ([]Mocks, error) {
-	var allData []Mocks
+// filesDirName is a directory convention for response payloads: anything
+// under a "__files" directory is never loaded as a mapping.
+const filesDirName = "__files"
+
+type mockFile struct {
+	path string
+	data Mocks
+}
+
+func loadMocks(dir string) ([]mockFile, error) {
+	type rawFile struct {
+		path string
+		data any
+		err  error
+	}
+	var raws []rawFile
 
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -19,6 +33,9 @@ func loadMocks(dir string) ([]Mocks, error) {
 		}
 
 		if d.IsDir() {
+			if d.Name() == filesDirName {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -31,12 +48,9 @@ func loadMocks(dir string) ([]Mocks, error) {
 			return fmt.Errorf("failed to read %s: %w", path, err)
 		}
 
-		var item Mocks
-		if err := json.Unmarshal(data, &item); err != nil {
-			return fmt.Errorf("Failed to unmarshal %s: %w", path, err)
-		}
-
-		allData = append(allData, item)
+		var item any
+		err = json.Unmarshal(data, &item)
+		raws = append(raws, rawFile{path: path, data: item, err: err})
 		return nil
 	})
 
@@ -44,7 +58,54 @@ func loadMocks(dir string) ([]Mocks, error) {
 		return nil, err
 	}
 
+	// Files referenced as a bodyFile (in response or responses) are payloads,
+	// not mappings, even when they live outside a __files directory.
+	referenced := make(map[string]bool)
+	for _, r := range raws {
+		for _, bodyFile := range referencedBodyFiles(r.data) {
+			if !filepath.IsAbs(bodyFile) {
+				referenced[filepath.Join(dir, bodyFile)] = true
+			}
+		}
+	}
+
+	var allData []mockFile
+	for _, r := range raws {
+		if referenced[filepath.Clean(r.path)] {
+			continue
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal %s: %w", r.path, r.err)
+		}
+		item, ok := r.data.(Mocks)
+		if !ok {
+			return nil, fmt.Errorf("Failed to unmarshal %s: mapping must be a JSON object", r.path)
+		}
+		allData = append(allData, mockFile{path: r.path, data: item})
+	}
+
 	fmt.Printf("Total mock items loaded: %d\n", len(allData))
 
 	return allData, nil
 }
+
+func referencedBodyFiles(item any) []string {
+	obj, ok := item.(Mocks)
+	if !ok {
+		return nil
+	}
+	responses, _ := obj["responses"].([]any)
+	responses = append(responses, obj["response"])
+
+	var files []string
+	for _, r := range responses {
+		resp, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		if bodyFile, _ := resp["bodyFile"].(string); bodyFile != "" {
+			files = append(files, bodyFile)
+		}
+	}
+	return files
+}
@@ -2,45 +2,116 @@ package generator
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/Srinu0342/mocknest/server/appdata"
 )
 
-func GenerateMappings() {
+// MocksDir is the root directory mappings (and bodyFile payloads) are loaded from.
+const MocksDir = "mocks"
+
+// ReloadStatus describes the most recent attempt to load the mocks directory.
+type ReloadStatus struct {
+	LastAttempt time.Time  `json:"lastAttempt"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// Error is set when the last attempt failed; the previous good index is still serving.
+	Error    string `json:"error,omitempty"`
+	Mappings int    `json:"mappings"`
+}
+
+var (
+	reloadMu        sync.Mutex
+	status          ReloadStatus
+	lastFingerprint string
+)
+
+// Status returns the outcome of the most recent load.
+func Status() ReloadStatus {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return status
+}
+
+// GenerateMappings performs the initial load. There is no last good index
+// to fall back on yet, so a broken mocks directory is returned as an error
+// and the server should refuse to start.
+func GenerateMappings() error {
 	log.Println("Loading mocks into runtime index...")
 
-	data, error := loadMocks("mocks")
-	if error != nil {
-		log.Fatal("Failed to load mocks:", error)
+	appdata.SetMocksRoot(MocksDir)
+	if err := Reload(); err != nil {
+		return err
+	}
+
+	log.Printf("Mappings loaded: %d (runtime index count=%d) done", Status().Mappings, appdata.Global.Count())
+	return nil
+}
+
+// Reload loads every mapping under MocksDir and swaps them in atomically.
+// Loading is all-or-nothing: any invalid file keeps the last good index.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	// Fingerprint before reading so edits made during the load trigger another pass.
+	fp := fingerprint(MocksDir)
+	now := time.Now()
+	status.LastAttempt = now
+
+	mappings, err := loadMappings(MocksDir)
+	if err == nil {
+		err = appdata.ReplaceFileMappings(mappings)
+	}
+	lastFingerprint = fp
+	if err != nil {
+		status.Error = err.Error()
+		return err
 	}
 
-	appdata.Global.Reset()
-	appdata.ResetMappings()
+	status.Error = ""
+	status.LastSuccess = &now
+	status.Mappings = len(mappings)
+	return nil
+}
+
+func loadMappings(dir string) ([]appdata.Mapping, error) {
+	data, err := loadMocks(dir)
+	if err != nil {
+		return nil, err
+	}
 
-	loaded := 0
-	for i, item := range data {
-		// loadMocks currently unmarshals into map[string]any.
+	var (
+		mappings []appdata.Mapping
+		errs     []error
+	)
+	for _, file := range data {
+		// loadMocks unmarshals into map[string]any.
 		// Re-marshal to JSON and unmarshal into the strict Mapping struct.
-		b, err := json.Marshal(item)
+		b, err := json.Marshal(file.data)
 		if err != nil {
-			log.Printf("Skipping mock[%d]: marshal failed: %v", i, err)
+			errs = append(errs, fmt.Errorf("%s: marshal failed: %w", file.path, err))
 			continue
 		}
 
 		var m appdata.Mapping
 		if err := json.Unmarshal(b, &m); err != nil {
-			log.Printf("Skipping mock[%d]: invalid mapping json: %v", i, err)
+			errs = append(errs, fmt.Errorf("%s: invalid mapping json: %w", file.path, err))
 			continue
 		}
 
-		if err := appdata.Global.Add(m); err != nil {
-			log.Printf("Skipping mapping id=%q: %v", m.ID, err)
+		if err := appdata.CheckBodyFile(m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.path, err))
 			continue
 		}
-		appdata.RegisterMapping(m)
-		loaded++
+		mappings = append(mappings, m)
 	}
 
-	log.Printf("Mappings loaded: %d/%d (runtime index count=%d) done", loaded, len(data), appdata.Global.Count())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return mappings, nil
 }
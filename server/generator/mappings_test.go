@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test that payload files are not loaded as mappings and that a broken file
+// fails the whole load instead of being skipped.
+func TestLoadMappings(t *testing.T) {
+	dir := t.TempDir()
+	appdata.SetMocksRoot(dir)
+	t.Cleanup(func() { appdata.SetMocksRoot(MocksDir) })
+
+	writeFile(t, filepath.Join(dir, "orders.json"),
+		`{"id":"orders","request":{"method":"GET","urlPattern":"/orders"},"response":{"bodyFile":"payloads/orders.json"}}`)
+	writeFile(t, filepath.Join(dir, "payloads", "orders.json"), `[{"id":1}]`)
+	writeFile(t, filepath.Join(dir, "__files", "ignored.json"), `not json`)
+
+	got, err := loadMappings(dir)
+	if err != nil {
+		t.Fatalf("loadMappings error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "orders" {
+		t.Fatalf("loadMappings = %+v, want only the orders mapping", got)
+	}
+
+	writeFile(t, filepath.Join(dir, "broken.json"),
+		`{"id":"broken","request":{"method":"GET","urlPattern":"/b"},"response":{"bodyFile":"missing.json"}}`)
+	_, err = loadMappings(dir)
+	if err == nil || !strings.Contains(err.Error(), `mapping "broken"`) {
+		t.Fatalf("loadMappings(missing bodyFile) error = %v, want error naming the mapping", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "broken.json"),
+		`{"id":"escape","request":{"method":"GET","urlPattern":"/b"},"response":{"bodyFile":"../../etc/passwd"}}`)
+	_, err = loadMappings(dir)
+	var verr *appdata.ValidationError
+	if !errors.As(err, &verr) || !strings.Contains(err.Error(), `mapping "escape"`) {
+		t.Fatalf("loadMappings(escaping bodyFile) error = %v, want validation error naming the mapping", err)
+	}
+}
+
+// Test that the fingerprint changes when a file is modified.
+func TestFingerprintChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.json"), `{}`)
+	before := fingerprint(dir)
+
+	writeFile(t, filepath.Join(dir, "a.json"), `{"id":"a"}`)
+	if after := fingerprint(dir); after == before {
+		t.Fatalf("fingerprint unchanged after edit: %s", after)
+	}
+}
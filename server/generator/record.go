@@ -0,0 +1,232 @@
+package generator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// RecordedDir is where record mode writes mapping files, below MocksDir.
+const RecordedDir = "recorded"
+
+// RecordConfig controls record mode: while enabled, every successfully
+// proxied exchange is written as a mapping file under mocks/recorded/.
+type RecordConfig struct {
+	Enabled bool `json:"enabled"`
+	// BodyFields are request body paths (dot paths or JSONPath) whose values
+	// the recorded stub also matches on; by default the body is ignored.
+	BodyFields []string `json:"bodyFields,omitempty"`
+}
+
+// Exchange is one proxied request and the upstream's answer.
+type Exchange struct {
+	Request appdata.IncomingRequest
+	Status  int
+	Headers map[string][]string
+	Body    []byte
+}
+
+// recordSkipHeaders are upstream response headers not worth replaying.
+var recordSkipHeaders = map[string]bool{
+	"Content-Length": true, "Date": true, "Connection": true,
+	"Keep-Alive": true, "Transfer-Encoding": true,
+}
+
+var (
+	recordMu     sync.Mutex
+	recordConfig RecordConfig
+)
+
+// SetRecordConfig turns record mode on or off.
+func SetRecordConfig(c RecordConfig) error {
+	for _, f := range c.BodyFields {
+		if _, _, err := appdata.LookupBody(nil, f); err != nil {
+			return fmt.Errorf("bodyFields %q: %w", f, err)
+		}
+	}
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	recordConfig = c
+	return nil
+}
+
+// GetRecordConfig returns the record mode configuration.
+func GetRecordConfig() RecordConfig {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	return recordConfig
+}
+
+// Record writes ex as a mapping file when record mode is on. Stubs are named
+// after their request signature, so an exchange whose signature was already
+// recorded is skipped, and so is a request repeating a query parameter: a
+// query matcher passes on any one value, so its stub would answer requests
+// the upstream was never asked. It returns the written path ("" if nothing
+// was written).
+func Record(ex Exchange) (string, error) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	if !recordConfig.Enabled {
+		return "", nil
+	}
+	for k, vs := range ex.Request.Query {
+		if len(vs) > 1 {
+			log.Printf("not recording %s %s: query parameter %q has %d values", ex.Request.Method, ex.Request.URL, k, len(vs))
+			return "", nil
+		}
+	}
+
+	m, sig := recordedMapping(ex, recordConfig.BodyFields)
+	dir := filepath.Join(MocksDir, RecordedDir)
+	path := filepath.Join(dir, recordedFileName(ex.Request, sig))
+	if _, err := os.Stat(path); err == nil {
+		return "", nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	// Write to a temp file first so the watcher never loads half a mapping.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// recordedMapping builds the stub for ex and its request signature: method,
+// exact path, exact query and the selected body fields.
+func recordedMapping(ex Exchange, bodyFields []string) (appdata.Mapping, string) {
+	req := ex.Request
+	method := strings.ToUpper(req.Method)
+	sig := []string{method, req.URL}
+
+	m := appdata.Mapping{
+		Description: fmt.Sprintf("Recorded %s %s", method, req.URL),
+		Request: appdata.Request{
+			Method:     method,
+			URLPattern: req.URL,
+			URLMatch:   "exact",
+			ExactQuery: true,
+		},
+		Response: recordedResponse(ex),
+		Metadata: appdata.Metadata{Tags: []string{"recorded"}},
+	}
+
+	keys := make([]string, 0, len(req.Query))
+	for k := range req.Query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vs := req.Query[k]
+		if len(vs) == 0 {
+			continue
+		}
+		if m.Request.QueryParams == nil {
+			m.Request.QueryParams = make(map[string]any)
+		}
+		m.Request.QueryParams[k] = vs[0]
+		sig = append(sig, "q:"+k+"="+vs[0])
+	}
+
+	for _, f := range bodyFields {
+		v, ok, _ := appdata.LookupBody(req.Body, f)
+		if !ok {
+			continue
+		}
+		if m.Request.Body == nil {
+			m.Request.Body = make(map[string]any)
+		}
+		m.Request.Body[f] = v
+		b, _ := json.Marshal(v)
+		sig = append(sig, "b:"+f+"="+string(b))
+	}
+
+	signature := strings.Join(sig, "\n")
+	m.ID = "recorded-" + hashSignature(signature)
+	return m, signature
+}
+
+// recordedResponse keeps JSON as a readable body, other text as rawBody and
+// anything else as base64Body. Repeated headers (e.g. Set-Cookie) go to
+// multiValueHeaders so each value is replayed on its own line.
+func recordedResponse(ex Exchange) appdata.Response {
+	resp := appdata.Response{Status: ex.Status}
+	for k, vs := range ex.Headers {
+		switch {
+		case recordSkipHeaders[k] || len(vs) == 0:
+			continue
+		case len(vs) > 1:
+			if resp.MultiValueHeaders == nil {
+				resp.MultiValueHeaders = make(map[string][]string)
+			}
+			resp.MultiValueHeaders[k] = slices.Clone(vs)
+		default:
+			if resp.Headers == nil {
+				resp.Headers = make(map[string]string)
+			}
+			resp.Headers[k] = vs[0]
+		}
+	}
+	if len(ex.Body) == 0 {
+		return resp
+	}
+
+	mt, _, _ := mime.ParseMediaType(resp.Headers["Content-Type"])
+	var parsed any
+	switch {
+	case (mt == "application/json" || strings.HasSuffix(mt, "+json")) && json.Unmarshal(ex.Body, &parsed) == nil:
+		resp.Body = parsed
+	case utf8.Valid(ex.Body):
+		resp.RawBody = string(ex.Body)
+	default:
+		resp.Base64Body = base64.StdEncoding.EncodeToString(ex.Body)
+	}
+	return resp
+}
+
+func recordedFileName(req appdata.IncomingRequest, signature string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '_'
+	}, strings.Trim(req.URL, "/"))
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	if slug == "" {
+		slug = "root"
+	}
+	return fmt.Sprintf("%s-%s-%s.json", strings.ToLower(req.Method), slug, hashSignature(signature))
+}
+
+func hashSignature(sig string) string {
+	h := fnv.New64a()
+	h.Write([]byte(sig))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// Test that record mode writes loadable stubs keyed by method, path, query
+// and selected body fields, and skips exchanges it already recorded.
+func TestRecord(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := SetRecordConfig(RecordConfig{Enabled: true, BodyFields: []string{"customer.id"}}); err != nil {
+		t.Fatalf("SetRecordConfig error = %v", err)
+	}
+	t.Cleanup(func() { _ = SetRecordConfig(RecordConfig{}) })
+
+	ex := Exchange{
+		Request: appdata.IncomingRequest{
+			Method: "post",
+			URL:    "/orders/search",
+			Query:  map[string][]string{"page": {"2"}},
+			Body:   map[string]any{"customer": map[string]any{"id": "c-1"}, "noise": 1.0},
+		},
+		Status: 200,
+		Headers: map[string][]string{
+			"Content-Type": {"application/json"},
+			"Date":         {"Mon, 01 Jan 2024 00:00:00 GMT"},
+			"Set-Cookie":   {"a=1; Path=/", "b=2; Path=/"},
+		},
+		Body: []byte(`{"orders":[{"id":1}]}`),
+	}
+
+	path, err := Record(ex)
+	if err != nil || path == "" {
+		t.Fatalf("Record = %q, %v", path, err)
+	}
+	if again, err := Record(ex); err != nil || again != "" {
+		t.Fatalf("Record(duplicate) = %q, %v, want it skipped", again, err)
+	}
+
+	other := ex
+	other.Request.Body = map[string]any{"customer": map[string]any{"id": "c-2"}}
+	if p, err := Record(other); err != nil || p == "" || p == path {
+		t.Fatalf("Record(other body) = %q, %v, want a new file", p, err)
+	}
+
+	mappings, err := loadMappings(MocksDir)
+	if err != nil {
+		t.Fatalf("loadMappings error = %v", err)
+	}
+	if len(mappings) != 2 {
+		t.Fatalf("loaded %d recorded mappings, want 2", len(mappings))
+	}
+
+	ri := appdata.NewRuntimeIndex()
+	for _, m := range mappings {
+		if !m.Request.ExactQuery {
+			t.Fatalf("recorded mapping %s without exactQuery", m.ID)
+		}
+		if err := ri.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", m.ID, err)
+		}
+	}
+	res, ok := ri.Match(ex.Request)
+	if !ok {
+		t.Fatal("recorded stub does not match the original request")
+	}
+	resp, _ := res.Render(ex.Request)
+	if resp.Status != 200 || resp.Headers["Date"] != "" || resp.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("recorded response = %+v", resp)
+	}
+	if got := resp.MultiValueHeaders["Set-Cookie"]; !slices.Equal(got, ex.Headers["Set-Cookie"]) {
+		t.Fatalf("recorded Set-Cookie = %q, want %q", got, ex.Headers["Set-Cookie"])
+	}
+	if _, ok := ri.Match(appdata.IncomingRequest{Method: "POST", URL: "/orders/search", Query: map[string][]string{"page": {"3"}}, Body: ex.Request.Body}); ok {
+		t.Fatal("recorded stub matched a different query")
+	}
+	extra := ex.Request
+	extra.Query = map[string][]string{"page": {"2"}, "q": {"foo"}}
+	if _, ok := ri.Match(extra); ok {
+		t.Fatal("recorded stub matched a request with an extra query param")
+	}
+}
+
+// Test that a request repeating a query parameter is not recorded, since a
+// single-value stub would also answer requests carrying only one of them.
+func TestRecordSkipsRepeatedQueryParams(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := SetRecordConfig(RecordConfig{Enabled: true}); err != nil {
+		t.Fatalf("SetRecordConfig error = %v", err)
+	}
+	t.Cleanup(func() { _ = SetRecordConfig(RecordConfig{}) })
+
+	path, err := Record(Exchange{
+		Request: appdata.IncomingRequest{Method: "GET", URL: "/items", Query: map[string][]string{"id": {"1", "2"}}},
+		Status:  200,
+	})
+	if err != nil || path != "" {
+		t.Fatalf("Record = %q, %v, want it skipped", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(MocksDir, RecordedDir)); !os.IsNotExist(err) {
+		t.Fatalf("recorded dir exists: %v", err)
+	}
+}
+
+func TestRecordDisabled(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path, err := Record(Exchange{Request: appdata.IncomingRequest{Method: "GET", URL: "/x"}, Status: 200})
+	if err != nil || path != "" {
+		t.Fatalf("Record = %q, %v, want nothing written", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(MocksDir, RecordedDir)); !os.IsNotExist(err) {
+		t.Fatalf("recorded dir exists: %v", err)
+	}
+}
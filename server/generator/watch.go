@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Watch polls MocksDir every interval and reloads when any file is added,
+// removed or modified. It uses only the standard library (no fsnotify), so it
+// also works on bind mounts and network filesystems. Call the returned
+// function to stop watching.
+func Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			reloadMu.Lock()
+			changed := fingerprint(MocksDir) != lastFingerprint
+			reloadMu.Unlock()
+			if !changed {
+				continue
+			}
+
+			log.Println("Mocks directory changed, reloading...")
+			if err := Reload(); err != nil {
+				log.Printf("Reload failed, keeping last good mappings: %v", err)
+				continue
+			}
+			log.Printf("Reload done: %d mappings", Status().Mappings)
+		}
+	}()
+	return func() { close(done) }
+}
+
+// fingerprint summarizes path, size and mtime of every file under dir.
+func fingerprint(dir string) string {
+	h := fnv.New64a()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
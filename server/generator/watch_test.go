@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// withMocksDir runs the test in a temporary working directory with an empty
+// mocks directory and a clean index.
+func withMocksDir(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir(MocksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+}
+
+func mappingJSON(id, url string) string {
+	return `{"id":"` + id + `","request":{"method":"GET","urlPattern":"` + url + `"},"response":{"status":200}}`
+}
+
+func matchedID(url string) string {
+	res, ok := appdata.Global.Match(appdata.IncomingRequest{Method: "GET", URL: url})
+	if !ok {
+		return ""
+	}
+	return res.Mapping.ID
+}
+
+// Test that Reload swaps in a good edit, keeps the last good index (and
+// reports the error) on a broken edit, and drops the mappings of a deleted file.
+func TestReload(t *testing.T) {
+	withMocksDir(t)
+	a := filepath.Join(MocksDir, "a.json")
+	b := filepath.Join(MocksDir, "b.json")
+	writeFile(t, a, mappingJSON("a", "/a"))
+	writeFile(t, b, mappingJSON("b", "/b"))
+
+	if err := GenerateMappings(); err != nil {
+		t.Fatalf("GenerateMappings error = %v", err)
+	}
+	if matchedID("/a") != "a" || matchedID("/b") != "b" {
+		t.Fatal("initial load did not install both mappings")
+	}
+
+	// A good edit swaps the index.
+	writeFile(t, a, mappingJSON("a", "/a2"))
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload error = %v", err)
+	}
+	if matchedID("/a") != "" || matchedID("/a2") != "a" {
+		t.Fatal("edited mapping not swapped in")
+	}
+
+	// A broken edit keeps the old index and shows the error.
+	writeFile(t, a, `{"id":"a","request":{"method":"GET","urlPattern":"(","urlMatch":"regex"}}`)
+	if err := Reload(); err == nil {
+		t.Fatal("Reload of a broken file succeeded")
+	}
+	if s := Status(); !strings.Contains(s.Error, `mapping "a"`) || s.Mappings != 2 {
+		t.Fatalf("Status = %+v, want the error and the 2 previous mappings", s)
+	}
+	if matchedID("/a2") != "a" || matchedID("/b") != "b" {
+		t.Fatal("broken edit replaced the last good index")
+	}
+
+	// Deleting a file removes its mappings (and fixing the other clears the error).
+	writeFile(t, a, mappingJSON("a", "/a3"))
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload error = %v", err)
+	}
+	if matchedID("/b") != "" || matchedID("/a3") != "a" {
+		t.Fatal("deleted file still serving or fix not loaded")
+	}
+	if s := Status(); s.Error != "" || s.Mappings != 1 || s.LastSuccess == nil {
+		t.Fatalf("Status = %+v, want 1 mapping and no error", s)
+	}
+}
+
+// Test that a broken mocks directory fails the initial load.
+func TestGenerateMappingsFailsOnBrokenFile(t *testing.T) {
+	withMocksDir(t)
+	writeFile(t, filepath.Join(MocksDir, "ok.json"), mappingJSON("ok", "/ok"))
+	writeFile(t, filepath.Join(MocksDir, "bad.json"), `{"id":`)
+
+	if err := GenerateMappings(); err == nil {
+		t.Fatal("GenerateMappings succeeded with a broken file")
+	}
+	if appdata.Global.Count() != 0 {
+		t.Fatalf("index has %d mappings after a failed initial load", appdata.Global.Count())
+	}
+}
+
+// Test that the watcher notices a changed fingerprint and reloads.
+func TestWatch(t *testing.T) {
+	withMocksDir(t)
+	writeFile(t, filepath.Join(MocksDir, "a.json"), mappingJSON("a", "/a"))
+	if err := GenerateMappings(); err != nil {
+		t.Fatalf("GenerateMappings error = %v", err)
+	}
+
+	stop := Watch(10 * time.Millisecond)
+	defer stop()
+
+	writeFile(t, filepath.Join(MocksDir, "b.json"), mappingJSON("b", "/b"))
+	deadline := time.Now().Add(2 * time.Second)
+	for matchedID("/b") != "b" {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not load the new file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
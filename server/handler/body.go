@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+const contentTypeJSON = "application/json"
+
+// buildResult turns a configured response into the exact bytes to send.
+//
+//   - rawBody / base64Body / bodyFile are written byte-for-byte.
+//   - body is JSON-encoded when it is structured JSON. A plain string is
+//     written as-is (as text/plain by default) unless a JSON Content-Type
+//     is configured, in which case it is encoded as a JSON string.
+//
+// A missing Content-Type is filled in from the body kind.
+func buildResult(resp appdata.Response) (Result, error) {
+	status := resp.Status
+	if status == 0 {
+		status = 200
+	}
+
+	headers := responseHeaders(resp)
+	contentType := headers.Get("Content-Type")
+	hasContentType := contentType != ""
+
+	var (
+		body        []byte
+		defaultType string
+	)
+	switch {
+	case resp.RawBody != "":
+		body = []byte(resp.RawBody)
+		defaultType = "text/plain; charset=utf-8"
+	case resp.Base64Body != "":
+		b, err := base64.StdEncoding.DecodeString(resp.Base64Body)
+		if err != nil {
+			return Result{}, fmt.Errorf("decode base64Body: %w", err)
+		}
+		body = b
+		defaultType = "application/octet-stream"
+	case resp.BodyFile != "":
+		b, err := appdata.ReadBodyFile(resp.BodyFile)
+		if err != nil {
+			return Result{}, fmt.Errorf("read bodyFile: %w", err)
+		}
+		body = b
+		defaultType = mime.TypeByExtension(filepath.Ext(resp.BodyFile))
+		if defaultType == "" {
+			defaultType = "application/octet-stream"
+		}
+	case resp.Body == nil:
+		// No body configured; keep the historical JSON default header.
+		defaultType = contentTypeJSON
+	default:
+		if s, ok := resp.Body.(string); ok && !isJSONContentType(contentType) {
+			body = []byte(s)
+			defaultType = "text/plain; charset=utf-8"
+			break
+		}
+		b, err := json.Marshal(resp.Body)
+		if err != nil {
+			return Result{}, fmt.Errorf("encode json body: %w", err)
+		}
+		body = b
+		defaultType = contentTypeJSON
+	}
+
+	if !hasContentType && defaultType != "" {
+		headers.Set("Content-Type", defaultType)
+	}
+	return Result{Status: status, Headers: headers, Body: body}, nil
+}
+
+func isJSONContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		mt = strings.ToLower(strings.TrimSpace(ct))
+	}
+	return mt == contentTypeJSON || strings.HasSuffix(mt, "+json")
+}
+
+// responseHeaders collects the mapping's headers, one entry per value of
+// multiValueHeaders.
+func responseHeaders(resp appdata.Response) http.Header {
+	headers := make(http.Header, len(resp.Headers)+len(resp.MultiValueHeaders)+1)
+	for k, v := range resp.Headers {
+		headers.Set(k, v)
+	}
+	for k, vs := range resp.MultiValueHeaders {
+		for _, v := range vs {
+			headers.Add(k, v)
+		}
+	}
+	return headers
+}
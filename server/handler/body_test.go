@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// Test that each body kind is written byte-for-byte with a sensible Content-Type.
+func TestBuildResultBodies(t *testing.T) {
+	dir := t.TempDir()
+	appdata.SetMocksRoot(dir)
+	t.Cleanup(func() { appdata.SetMocksRoot("mocks") })
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte("<h1>hi</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		resp     appdata.Response
+		wantBody string
+		wantType string
+	}{
+		{"json body", appdata.Response{Body: map[string]any{"ok": true}}, `{"ok":true}`, "application/json"},
+		{"string body", appdata.Response{Body: "hi"}, "hi", "text/plain; charset=utf-8"},
+		{"string body with json type", appdata.Response{Headers: map[string]string{"Content-Type": "application/json"}, Body: "hi"}, `"hi"`, "application/json"},
+		{"string body with html type", appdata.Response{Headers: map[string]string{"content-type": "text/html"}, Body: "<p>hi</p>"}, "<p>hi</p>", "text/html"},
+		{"raw body", appdata.Response{RawBody: "plain text"}, "plain text", "text/plain; charset=utf-8"},
+		{"raw xml body", appdata.Response{Headers: map[string]string{"Content-Type": "application/xml"}, RawBody: "<a/>"}, "<a/>", "application/xml"},
+		{"base64 body", appdata.Response{Base64Body: "AAEC"}, "\x00\x01\x02", "application/octet-stream"},
+		{"body file", appdata.Response{BodyFile: "page.html"}, "<h1>hi</h1>", "text/html; charset=utf-8"},
+		{"no body", appdata.Response{Status: 204}, "", "application/json"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := buildResult(tc.resp)
+			if err != nil {
+				t.Fatalf("buildResult error = %v", err)
+			}
+			if string(res.Body) != tc.wantBody {
+				t.Errorf("Body = %q, want %q", res.Body, tc.wantBody)
+			}
+			if ct := res.Headers.Get("Content-Type"); ct != tc.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tc.wantType)
+			}
+		})
+	}
+}
+
+// Test that multiValueHeaders are sent once per value next to the single headers.
+func TestBuildResultMultiValueHeaders(t *testing.T) {
+	res, err := buildResult(appdata.Response{
+		Headers:           map[string]string{"X-Id": "1"},
+		MultiValueHeaders: map[string][]string{"set-cookie": {"a=1", "b=2"}},
+	})
+	if err != nil {
+		t.Fatalf("buildResult error = %v", err)
+	}
+	if got := res.Headers.Values("Set-Cookie"); !slices.Equal(got, []string{"a=1", "b=2"}) {
+		t.Errorf("Set-Cookie = %q, want both values", got)
+	}
+	if got := res.Headers.Get("X-Id"); got != "1" {
+		t.Errorf("X-Id = %q, want 1", got)
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// randomDataSize is how many garbage bytes RANDOM_DATA_THEN_CLOSE sends.
+const randomDataSize = 1024
+
+// ErrNoHijack is returned by WriteFault when the connection behind w cannot
+// be hijacked (e.g. HTTP/2). The caller should abort the request with
+// http.ErrAbortHandler, which resets the stream instead.
+var ErrNoHijack = errors.New("fault: connection cannot be hijacked")
+
+// WriteFault breaks the connection behind w as described by res.Fault.
+// It hijacks the connection, so nothing else may be written to w afterwards.
+func WriteFault(w http.ResponseWriter, res Result) error {
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoHijack, err)
+	}
+	defer conn.Close()
+
+	switch res.Fault {
+	case appdata.FaultConnectionResetByPeer:
+		// A zero linger time makes Close send RST instead of FIN.
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			return tcp.SetLinger(0)
+		}
+		return nil
+	case appdata.FaultEmptyResponse:
+		return nil
+	case appdata.FaultMalformedChunk:
+		return writeMalformedChunk(buf.Writer, res)
+	case appdata.FaultRandomDataThenClose:
+		garbage := make([]byte, randomDataSize)
+		if _, err := rand.Read(garbage); err != nil {
+			return err
+		}
+		if _, err := buf.Write(garbage); err != nil {
+			return err
+		}
+		return buf.Flush()
+	default:
+		return fmt.Errorf("unknown fault %q", res.Fault)
+	}
+}
+
+// writeMalformedChunk sends a valid status line and headers announcing a
+// chunked body, followed by a chunk whose size line is not hex.
+func writeMalformedChunk(w *bufio.Writer, res Result) error {
+	fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", res.Status, http.StatusText(res.Status))
+	h := res.Headers.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Del("Content-Length")
+	h.Set("Transfer-Encoding", "chunked")
+	if err := h.Write(w); err != nil {
+		return err
+	}
+	fmt.Fprint(w, "\r\n")
+	fmt.Fprint(w, "lskdu018973t09sylgasjkfg1][]'./.sdlv\r\n")
+	return w.Flush()
+}
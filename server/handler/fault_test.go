@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// Test that every fault makes a real HTTP client fail instead of receiving
+// a well-formed response.
+func TestWriteFault(t *testing.T) {
+	faults := []string{
+		appdata.FaultConnectionResetByPeer,
+		appdata.FaultEmptyResponse,
+		appdata.FaultMalformedChunk,
+		appdata.FaultRandomDataThenClose,
+	}
+
+	for _, fault := range faults {
+		t.Run(fault, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res := Result{Status: 200, Headers: http.Header{"Content-Type": {"application/json"}}, Fault: fault}
+				if err := WriteFault(w, res); err != nil {
+					t.Errorf("WriteFault error = %v", err)
+				}
+			}))
+			defer srv.Close()
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			if fault != appdata.FaultMalformedChunk {
+				t.Fatalf("Get succeeded with status %d, want a transport error", resp.StatusCode)
+			}
+			if _, err := io.ReadAll(resp.Body); err == nil {
+				t.Fatalf("reading malformed chunked body succeeded, want error")
+			}
+		})
+	}
+}
+
+// serveOverHTTP runs req through Serve behind a real server, so faults can
+// hijack the connection, and waits until the call is recorded.
+func serveOverHTTP(t *testing.T, req appdata.IncomingRequest) {
+	t.Helper()
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		_ = Serve(r.Context(), w, req)
+	}))
+	defer srv.Close()
+
+	if resp, err := srv.Client().Get(srv.URL); err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	<-done
+}
+
+// Test that a fault mapping breaks the connection and is recorded in history.
+func TestServeRecordsFault(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:       "reset",
+		Request:  appdata.Request{Method: "GET", URLPattern: "/boom", URLMatch: "exact"},
+		Response: appdata.Response{Fault: appdata.FaultConnectionResetByPeer},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	serveOverHTTP(t, appdata.IncomingRequest{Method: "GET", URL: "/boom"})
+
+	last := lastCall(t)
+	if last.MappingID != "reset" || last.Fault != appdata.FaultConnectionResetByPeer || last.Status != 0 {
+		t.Fatalf("recorded fault = %q status = %d", last.Fault, last.Status)
+	}
+}
+
+// Test that a chaos fault replaces the response and shows up in history.
+func TestServeRecordsChaos(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:       "charge",
+		Request:  appdata.Request{Method: "POST", URLPattern: "/charge", URLMatch: "exact"},
+		Response: appdata.Response{Status: 201},
+		Metadata: appdata.Metadata{Tags: []string{"payments"}},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	profile := appdata.ChaosProfile{
+		Enabled: true,
+		Rules:   []appdata.ChaosRule{{Tags: []string{"payments"}, ErrorRate: 1, Fault: appdata.FaultEmptyResponse}},
+	}
+	if err := appdata.SetChaosProfile(profile); err != nil {
+		t.Fatalf("SetChaosProfile error = %v", err)
+	}
+	t.Cleanup(func() { _ = appdata.SetChaosProfile(appdata.ChaosProfile{}) })
+
+	serveOverHTTP(t, appdata.IncomingRequest{Method: "POST", URL: "/charge"})
+
+	last := lastCall(t)
+	if last.Chaos == nil || last.Chaos.Fault != appdata.FaultEmptyResponse || last.Fault != appdata.FaultEmptyResponse {
+		t.Fatalf("recorded chaos = %+v, fault = %q", last.Chaos, last.Fault)
+	}
+}
+
+// Test that a fault on a connection that cannot be hijacked is still
+// recorded before the request is aborted.
+func TestServeFaultWithoutHijack(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:       "garbage",
+		Request:  appdata.Request{Method: "GET", URLPattern: "/garbage", URLMatch: "exact"},
+		Response: appdata.Response{Status: 200, Fault: appdata.FaultRandomDataThenClose},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Fatalf("recovered %v, want http.ErrAbortHandler", r)
+			}
+		}()
+		// ResponseRecorder does not support Hijack, like an HTTP/2 stream.
+		_ = Serve(context.Background(), httptest.NewRecorder(), appdata.IncomingRequest{Method: "GET", URL: "/garbage"})
+	}()
+
+	if last := lastCall(t); last.MappingID != "garbage" || last.Fault != appdata.FaultRandomDataThenClose || last.Status != 0 {
+		t.Fatalf("recorded %q fault %q status %d", last.MappingID, last.Fault, last.Status)
+	}
+}
@@ -1,73 +1,230 @@
 package handler
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Srinu0342/mocknest/server/appdata"
 )
 
-// Handler is the main entrypoint for matching an HTTP request against the
-// loaded mock mappings. It returns the HTTP status, headers, and body to send.
-func Handler(req appdata.IncomingRequest) (int, map[string]string, any) {
-	mapping, ok := appdata.Global.FindBestMatch(req)
+// Result is the fully-resolved response the HTTP layer writes back verbatim.
+type Result struct {
+	Status int
+	// Headers may repeat a header, e.g. several Set-Cookie from an upstream.
+	Headers http.Header
+	Body    []byte
+	// Fault, when set, tells the HTTP layer to break the connection instead
+	// of writing the response (see WriteFault).
+	Fault string
+	// DribbleChunks > 0 spreads Body over DribbleDuration (see WriteResult).
+	DribbleChunks   int
+	DribbleDuration time.Duration
+}
+
+// NearMissesIn404 is how many near misses (see appdata.NearMiss) the
+// "no mock mapping found" body lists; 0 leaves them out. Set it before
+// serving requests.
+var NearMissesIn404 int
+
+// Serve is the main entrypoint for matching an HTTP request against the
+// loaded mock mappings. It writes the matched response on w and records the
+// call once the response is written. Configured delays and dribbles stop
+// early when ctx is done (the client went away); the call is then recorded
+// with status 0. Unmatched requests go to the fallback upstream when one is
+// configured (see appdata.ProxyConfig).
+//
+// A fault on a connection that cannot be hijacked is recorded, then the
+// request is aborted with http.ErrAbortHandler.
+func Serve(ctx context.Context, w http.ResponseWriter, req appdata.IncomingRequest) error {
+	start := time.Now()
+	res, rec, cancelled := resolve(ctx, req)
+	var err error
+	if !cancelled {
+		// Nobody is listening after a cancelled delay; skip the write.
+		err = WriteResult(ctx, w, res)
+		cancelled = ctx.Err() != nil
+	}
+	recordCall(rec, res, cancelled, start)
+	if errors.Is(err, ErrNoHijack) {
+		panic(http.ErrAbortHandler)
+	}
+	return err
+}
+
+// resolve matches req and builds the result to send, along with the call
+// record still missing the outcome (see recordCall). cancelled reports that
+// ctx was done during the delay.
+func resolve(ctx context.Context, req appdata.IncomingRequest) (Result, appdata.CallRecord, bool) {
+	match, ok := appdata.Global.Match(req)
 
 	var (
-		status    int
-		headers   map[string]string
-		respBody  any
+		resp      appdata.Response
 		mappingID string
+		cancelled bool
+		chaos     *appdata.ChaosInjection
+		target    *proxyTarget
+		delay     time.Duration
 	)
 
 	if !ok {
-		// No mapping matched: return a simple 404 JSON body.
-		status = httpStatusNotFound()
-		headers = map[string]string{
-			"Content-Type": "application/json",
+		if fallback := appdata.GetProxyConfig(); fallback.BaseURL != "" {
+			target = fallbackTarget(fallback)
 		}
-		respBody = map[string]any{
+		// No mapping matched: return a simple 404 JSON body.
+		body := map[string]any{
 			"error":  "no mock mapping found",
 			"method": req.Method,
 			"url":    req.URL,
 		}
+		if NearMissesIn404 > 0 && target == nil {
+			body["nearMisses"] = appdata.Global.NearMisses(req, NearMissesIn404)
+		}
+		resp = appdata.Response{
+			Status: http.StatusNotFound,
+			Body:   body,
+		}
 	} else {
-		mappingID = mapping.ID
-		resp := mapping.Response
-		status = resp.Status
-		if status == 0 {
-			status = 200
+		mappingID = match.Mapping.ID
+		rendered, err := match.Render(req)
+		if err != nil {
+			// A broken template is a mock configuration problem; surface it
+			// to the caller instead of sending a half-rendered response.
+			rendered = errorResponse("response template failed", mappingID, err)
 		}
+		resp = rendered
 
-		// Optional artificial delay for simulating latency.
-		if resp.FixedDelayMs > 0 {
-			time.Sleep(time.Duration(resp.FixedDelayMs) * time.Millisecond)
+		chaos = appdata.ApplyChaos(match.Mapping)
+		if chaos != nil && (chaos.Status != 0 || chaos.Fault != "") {
+			resp = chaosResponse(resp, chaos, mappingID)
 		}
 
-		headers = make(map[string]string, len(resp.Headers))
-		for k, v := range resp.Headers {
-			headers[k] = v
+		// A chaos failure replaces the whole response, proxying included.
+		if resp.ProxyBaseURL != "" {
+			target = mappingTarget(resp)
 		}
-		// Ensure Content-Type is set for JSON responses if not provided.
-		if _, ok := headers["Content-Type"]; !ok {
-			headers["Content-Type"] = "application/json"
+
+		// Optional artificial delay for simulating latency.
+		delay = resp.Delay() + chaos.Delay()
+		cancelled = !sleep(ctx, delay)
+	}
+
+	var (
+		res     Result
+		proxied *proxyOutcome
+	)
+	if target != nil && !cancelled {
+		res, proxied = forward(ctx, req, target, responseHeaders(resp))
+	} else {
+		var err error
+		res, err = buildResult(resp)
+		if err != nil {
+			res, _ = buildResult(errorResponse("response body failed", mappingID, err))
+			resp = appdata.Response{}
 		}
-		respBody = resp.Body
 	}
+	res.Fault = resp.Fault
+	res.DribbleChunks, res.DribbleDuration, _ = resp.Dribble()
 
-	// Record the call in global in-memory history.
-	appdata.RecordCall(appdata.CallRecord{
-		Time:        time.Now(),
-		Method:      req.Method,
-		URL:         req.URL,
-		Query:       req.Query,
-		RequestBody: req.Body,
-		MappingID:   mappingID,
-		Status:      status,
-	})
-
-	return status, headers, respBody
+	rec := appdata.CallRecord{
+		Time:           time.Now(),
+		Method:         req.Method,
+		URL:            req.URL,
+		Query:          req.Query,
+		PathParams:     match.PathParams,
+		RequestBody:    req.Body,
+		MappingID:      mappingID,
+		Fault:          res.Fault,
+		Chaos:          chaos,
+		ProxiedTo:      proxied.url(),
+		ProxyStatus:    proxied.status(),
+		ProxyError:     proxied.errorText(),
+		RemoteAddr:     req.RemoteAddr,
+		RequestHeaders: req.Headers,
+		DelayMs:        int(delay / time.Millisecond),
+	}
+	return res, rec, cancelled
+}
+
+// recordCall completes rec with what the client saw of res and appends it to
+// the global in-memory history.
+func recordCall(rec appdata.CallRecord, res Result, cancelled bool, start time.Time) {
+	rec.Status = recordedStatus(res, cancelled)
+	if rec.Status != 0 {
+		rec.ResponseHeaders = res.Headers
+		rec.ResponseBody, rec.ResponseBase64Body = recordedBody(res.Body)
+	}
+	rec.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	appdata.RecordCall(rec)
+}
+
+// recordedBody keeps a response body for call history the way request
+// bodies are kept: parsed JSON, else text, else base64.
+func recordedBody(body []byte) (any, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	var parsed any
+	switch {
+	case json.Unmarshal(body, &parsed) == nil:
+		return parsed, ""
+	case utf8.Valid(body):
+		return string(body), ""
+	}
+	return nil, base64.StdEncoding.EncodeToString(body)
 }
 
-func httpStatusNotFound() int {
-	// Avoid importing net/http just for the constant; keep it simple.
-	return 404
+// recordedStatus is the status the client actually saw; faults other than a
+// malformed chunk never send a status line, and a client that left during a
+// delay or a dribble never got the whole response.
+func recordedStatus(res Result, cancelled bool) int {
+	if cancelled || res.Fault != "" && res.Fault != appdata.FaultMalformedChunk {
+		return 0
+	}
+	return res.Status
+}
+
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// chaosResponse replaces resp with the failure chaos mode injected, keeping
+// the mapping's configured delays.
+func chaosResponse(resp appdata.Response, chaos *appdata.ChaosInjection, mappingID string) appdata.Response {
+	return appdata.Response{
+		Status: chaos.Status,
+		Body: map[string]any{
+			"error":     "chaos: injected failure",
+			"mappingId": mappingID,
+		},
+		Fault:             chaos.Fault,
+		FixedDelayMs:      resp.FixedDelayMs,
+		DelayDistribution: resp.DelayDistribution,
+	}
+}
+
+func errorResponse(msg, mappingID string, err error) appdata.Response {
+	return appdata.Response{
+		Status: 500,
+		Body: map[string]any{
+			"error":     msg,
+			"mappingId": mappingID,
+			"details":   err.Error(),
+		},
+	}
 }
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+// serve runs req through Serve on a recorder.
+func serve(t *testing.T, req appdata.IncomingRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := Serve(context.Background(), rec, req); err != nil {
+		t.Fatalf("Serve error = %v", err)
+	}
+	return rec
+}
+
+// Test that the 404 body lists near misses only when opted in.
+func TestServeNearMissesIn404(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+	t.Cleanup(func() { NearMissesIn404 = 0 })
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:      "user",
+		Request: appdata.Request{Method: "GET", URLPattern: "/users", URLMatch: "exact", QueryParams: map[string]any{"id": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	req := appdata.IncomingRequest{Method: "GET", URL: "/users", Query: map[string][]string{"id": {"2"}}}
+
+	decode := func(res *httptest.ResponseRecorder) map[string]any {
+		t.Helper()
+		var body map[string]any
+		if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+			t.Fatalf("404 body %q: %v", res.Body, err)
+		}
+		return body
+	}
+
+	if body := decode(serve(t, req)); body["nearMisses"] != nil {
+		t.Fatalf("near misses listed without opting in: %v", body)
+	}
+
+	NearMissesIn404 = 3
+	res := serve(t, req)
+	if res.Code != 404 {
+		t.Fatalf("status = %d, want 404", res.Code)
+	}
+	misses, _ := decode(res)["nearMisses"].([]any)
+	if len(misses) != 1 {
+		t.Fatalf("nearMisses = %v, want one entry", misses)
+	}
+	reasons, _ := misses[0].(map[string]any)["reasons"].([]any)
+	if len(reasons) != 1 || reasons[0] != "query id expected 1 got 2" {
+		t.Fatalf("reasons = %v", reasons)
+	}
+}
+
+// Test that history keeps the request headers (redacted), the response
+// actually sent, the client address and the delay applied.
+func TestServeRecordsExchange(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:      "login",
+		Request: appdata.Request{Method: "POST", URLPattern: "/login", URLMatch: "exact"},
+		Response: appdata.Response{
+			Status:       200,
+			Headers:      map[string]string{"Set-Cookie": "session=abc", "X-Trace": "t-1"},
+			Body:         map[string]any{"ok": true},
+			FixedDelayMs: 20,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	headers := map[string][]string{"Authorization": {"Bearer secret"}, "X-Tenant": {"acme"}}
+	serve(t, appdata.IncomingRequest{
+		Method:     "POST",
+		URL:        "/login",
+		Headers:    headers,
+		RemoteAddr: "10.0.0.7:51234",
+	})
+
+	rec := lastCall(t)
+	if got := rec.RequestHeaders["Authorization"]; len(got) != 1 || got[0] != "[REDACTED]" {
+		t.Errorf("Authorization = %v, want redacted", got)
+	}
+	if got := rec.RequestHeaders["X-Tenant"]; len(got) != 1 || got[0] != "acme" {
+		t.Errorf("X-Tenant = %v, want acme", got)
+	}
+	if headers["Authorization"][0] != "Bearer secret" {
+		t.Errorf("redaction changed the request headers: %v", headers)
+	}
+	if got := http.Header(rec.ResponseHeaders); got.Get("Set-Cookie") != "[REDACTED]" || got.Get("X-Trace") != "t-1" {
+		t.Errorf("ResponseHeaders = %v", rec.ResponseHeaders)
+	}
+	if body, _ := rec.ResponseBody.(map[string]any); body["ok"] != true {
+		t.Errorf("ResponseBody = %v, want {ok: true}", rec.ResponseBody)
+	}
+	if rec.RemoteAddr != "10.0.0.7:51234" || rec.DelayMs != 20 || rec.DurationMs < 20 {
+		t.Errorf("RemoteAddr %q, DelayMs %d, DurationMs %v", rec.RemoteAddr, rec.DelayMs, rec.DurationMs)
+	}
+}
+
+func TestRecordedBody(t *testing.T) {
+	tests := []struct {
+		body   []byte
+		want   any
+		want64 string
+	}{
+		{nil, nil, ""},
+		{[]byte(`{"a":1}`), map[string]any{"a": float64(1)}, ""},
+		{[]byte("plain"), "plain", ""},
+		{[]byte{0xff, 0x00}, nil, "/wA="},
+	}
+	for _, tt := range tests {
+		got, got64 := recordedBody(tt.body)
+		if !reflect.DeepEqual(got, tt.want) || got64 != tt.want64 {
+			t.Errorf("recordedBody(%q) = %v, %q; want %v, %q", tt.body, got, got64, tt.want, tt.want64)
+		}
+	}
+}
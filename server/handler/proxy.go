@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+	"github.com/Srinu0342/mocknest/server/generator"
+)
+
+// defaultProxyTimeout bounds an upstream exchange when no timeout is configured.
+const defaultProxyTimeout = 10 * time.Second
+
+// hopHeaders only describe a single connection and are never forwarded.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+var proxyClient = &http.Client{
+	// Hand redirects back to the caller, as a transparent proxy would.
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// proxyTarget is where and how to forward one request.
+type proxyTarget struct {
+	baseURL string
+	// headerRewrites are applied in order; an empty value removes the header.
+	headerRewrites []map[string]string
+	timeout        time.Duration
+}
+
+func fallbackTarget(c appdata.ProxyConfig) *proxyTarget {
+	return &proxyTarget{
+		baseURL:        c.BaseURL,
+		headerRewrites: []map[string]string{c.RequestHeaders},
+		timeout:        proxyTimeout(c.TimeoutMs, 0),
+	}
+}
+
+func mappingTarget(resp appdata.Response) *proxyTarget {
+	global := appdata.GetProxyConfig()
+	return &proxyTarget{
+		baseURL:        resp.ProxyBaseURL,
+		headerRewrites: []map[string]string{global.RequestHeaders, resp.ProxyRequestHeaders},
+		timeout:        proxyTimeout(resp.ProxyTimeoutMs, global.TimeoutMs),
+	}
+}
+
+func proxyTimeout(ms, fallbackMs int) time.Duration {
+	switch {
+	case ms > 0:
+		return time.Duration(ms) * time.Millisecond
+	case fallbackMs > 0:
+		return time.Duration(fallbackMs) * time.Millisecond
+	}
+	return defaultProxyTimeout
+}
+
+// proxyOutcome is what happened upstream, for call history. Its methods
+// accept a nil receiver (the call was not proxied).
+type proxyOutcome struct {
+	target string
+	code   int
+	err    error
+}
+
+func (o *proxyOutcome) url() string {
+	if o == nil {
+		return ""
+	}
+	return o.target
+}
+
+func (o *proxyOutcome) status() int {
+	if o == nil {
+		return 0
+	}
+	return o.code
+}
+
+func (o *proxyOutcome) errorText() string {
+	if o == nil || o.err == nil {
+		return ""
+	}
+	return o.err.Error()
+}
+
+// forward sends req to the target upstream and returns its response, with
+// overrides (the mapping's response headers) applied on top. Upstream
+// failures become a 502, or a 504 on timeout.
+func forward(ctx context.Context, req appdata.IncomingRequest, t *proxyTarget, overrides http.Header) (Result, *proxyOutcome) {
+	target := upstreamURL(t.baseURL, req)
+	outcome := &proxyOutcome{target: target}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	out, err := http.NewRequestWithContext(ctx, strings.ToUpper(req.Method), target, bytes.NewReader(req.RawBody))
+	if err != nil {
+		outcome.err = err
+		return proxyError(http.StatusBadGateway, target, err), outcome
+	}
+	for k, vs := range req.Headers {
+		for _, v := range vs {
+			out.Header.Add(k, v)
+		}
+	}
+	removeHopHeaders(out.Header)
+	for _, rewrites := range t.headerRewrites {
+		for k, v := range rewrites {
+			if v == "" {
+				out.Header.Del(k)
+			} else {
+				out.Header.Set(k, v)
+			}
+		}
+	}
+	if host := out.Header.Get("Host"); host != "" {
+		out.Host = host
+	}
+
+	resp, err := proxyClient.Do(out)
+	if err == nil {
+		defer resp.Body.Close()
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		if err == nil {
+			outcome.code = resp.StatusCode
+			res := upstreamResult(resp, body)
+			record(req, res)
+			applyHeaderOverrides(res.Headers, overrides)
+			return res, outcome
+		}
+	}
+
+	outcome.err = err
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	return proxyError(status, target, err), outcome
+}
+
+// upstreamURL joins the upstream base with the request path and query,
+// keeping their escaping and parameter order as the client sent them.
+func upstreamURL(base string, req appdata.IncomingRequest) string {
+	u, _ := url.Parse(base) // validated when configured
+	path, query := req.EscapedPath, req.RawQuery
+	if path == "" {
+		// Built without the raw request line (e.g. in tests).
+		path = (&url.URL{Path: req.URL}).EscapedPath()
+	}
+	if query == "" {
+		query = url.Values(req.Query).Encode()
+	}
+	joined := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(path, "/")
+	if p, err := url.PathUnescape(joined); err == nil {
+		u.Path, u.RawPath = p, joined
+	}
+	u.RawQuery = query
+	return u.String()
+}
+
+func upstreamResult(resp *http.Response, body []byte) Result {
+	headers := resp.Header.Clone()
+	removeHopHeaders(headers)
+	headers.Del("Content-Length")
+	return Result{Status: resp.StatusCode, Headers: headers, Body: body}
+}
+
+// removeHopHeaders drops hopHeaders and every header the Connection header
+// names as hop-by-hop (RFC 9110 section 7.6.1).
+func removeHopHeaders(h http.Header) {
+	for _, v := range h["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+func applyHeaderOverrides(headers, overrides http.Header) {
+	for k, vs := range overrides {
+		headers[k] = slices.Clone(vs)
+	}
+}
+
+// record snapshots the upstream's own answer (before any mapping header
+// overrides) when record mode is on.
+func record(req appdata.IncomingRequest, res Result) {
+	path, err := generator.Record(generator.Exchange{
+		Request: req,
+		Status:  res.Status,
+		Headers: res.Headers,
+		Body:    res.Body,
+	})
+	switch {
+	case err != nil:
+		log.Printf("failed to record %s %s: %v", req.Method, req.URL, err)
+	case path != "":
+		log.Printf("recorded %s %s to %s", req.Method, req.URL, path)
+	}
+}
+
+func proxyError(status int, target string, err error) Result {
+	res, _ := buildResult(appdata.Response{
+		Status: status,
+		Body: map[string]any{
+			"error":    "proxy request failed",
+			"upstream": target,
+			"details":  err.Error(),
+		},
+	})
+	return res
+}
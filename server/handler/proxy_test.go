@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+func withProxyConfig(t *testing.T, c appdata.ProxyConfig) {
+	t.Helper()
+	if err := appdata.SetProxyConfig(c); err != nil {
+		t.Fatalf("SetProxyConfig error = %v", err)
+	}
+	t.Cleanup(func() { _ = appdata.SetProxyConfig(appdata.ProxyConfig{}) })
+}
+
+func lastCall(t *testing.T) appdata.CallRecord {
+	t.Helper()
+	history := appdata.GetCallHistory()
+	if len(history) == 0 {
+		t.Fatal("empty call history")
+	}
+	return history[len(history)-1]
+}
+
+// Test that unmatched requests reach the fallback upstream with path, query,
+// body and rewritten headers, and that the upstream status is recorded.
+func TestServeProxyFallback(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Seen", r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+" "+string(body))
+		w.Header().Set("X-Token", r.Header.Get("Authorization"))
+		w.Header().Set("X-Env", r.Header.Get("X-Env"))
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("from upstream"))
+	}))
+	defer upstream.Close()
+
+	withProxyConfig(t, appdata.ProxyConfig{
+		BaseURL:        upstream.URL + "/api/",
+		RequestHeaders: map[string]string{"Authorization": "", "X-Env": "mock"},
+	})
+
+	res := serve(t, appdata.IncomingRequest{
+		Method:  "POST",
+		URL:     "/orders",
+		Query:   map[string][]string{"id": {"7"}},
+		Headers: map[string][]string{"Authorization": {"Bearer secret"}},
+		RawBody: []byte(`{"a":1}`),
+	})
+
+	if res.Code != http.StatusTeapot || res.Body.String() != "from upstream" {
+		t.Fatalf("got %d %q, want 418 from upstream", res.Code, res.Body)
+	}
+	if got := res.Header().Get("X-Seen"); got != `POST /api/orders?id=7 {"a":1}` {
+		t.Errorf("upstream saw %q", got)
+	}
+	if res.Header().Get("X-Token") != "" || res.Header().Get("X-Env") != "mock" {
+		t.Errorf("header rewrite failed: token %q env %q", res.Header().Get("X-Token"), res.Header().Get("X-Env"))
+	}
+
+	call := lastCall(t)
+	if call.ProxiedTo != upstream.URL+"/api/orders?id=7" || call.ProxyStatus != http.StatusTeapot || call.Status != http.StatusTeapot {
+		t.Fatalf("recorded %+v", call)
+	}
+}
+
+// Test per-mapping proxying with response header overrides and timeouts.
+func TestServeProxyMapping(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("real"))
+	}))
+	defer upstream.Close()
+
+	mappings := []appdata.Mapping{
+		{
+			ID:      "passthrough",
+			Request: appdata.Request{Method: "GET", URLPattern: "/fast", URLMatch: "exact"},
+			Response: appdata.Response{
+				ProxyBaseURL: upstream.URL,
+				Headers:      map[string]string{"cache-control": "max-age=60"},
+			},
+		},
+		{
+			ID:       "slow",
+			Request:  appdata.Request{Method: "GET", URLPattern: "/slow", URLMatch: "exact"},
+			Response: appdata.Response{ProxyBaseURL: upstream.URL, ProxyTimeoutMs: 50},
+		},
+	}
+	for _, m := range mappings {
+		if err := appdata.Global.Add(m); err != nil {
+			t.Fatalf("Add(%s) error = %v", m.ID, err)
+		}
+	}
+
+	res := serve(t, appdata.IncomingRequest{Method: "GET", URL: "/fast"})
+	if res.Code != 200 || res.Body.String() != "real" || res.Header().Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("got %d %q %v", res.Code, res.Body, res.Header())
+	}
+
+	res = serve(t, appdata.IncomingRequest{Method: "GET", URL: "/slow"})
+	if res.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", res.Code)
+	}
+	if call := lastCall(t); call.MappingID != "slow" || call.ProxyStatus != 0 || call.ProxyError == "" {
+		t.Fatalf("recorded %+v", call)
+	}
+}
+
+// Test that the upstream gets the path and query exactly as the client sent
+// them, and that repeated upstream headers reach the client separately.
+func TestServeProxyPreservesRequestLineAndRepeatedHeaders(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-URI", r.RequestURI)
+		w.Header().Add("Set-Cookie", "a=1; Path=/")
+		w.Header().Add("Set-Cookie", "b=2; Path=/")
+	}))
+	defer upstream.Close()
+	withProxyConfig(t, appdata.ProxyConfig{BaseURL: upstream.URL + "/base"})
+
+	res := serve(t, appdata.IncomingRequest{
+		Method:      "GET",
+		URL:         "/files/a/b c",
+		EscapedPath: "/files/a%2Fb%20c",
+		Query:       map[string][]string{"z": {"1"}, "a": {"2", "1"}},
+		RawQuery:    "z=1&a=2&a=1&q=x+y",
+	})
+
+	if got, want := res.Header().Get("X-Request-URI"), "/base/files/a%2Fb%20c?z=1&a=2&a=1&q=x+y"; got != want {
+		t.Errorf("upstream request URI = %q, want %q", got, want)
+	}
+	cookies := res.Result().Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "a" || cookies[1].Name != "b" {
+		t.Errorf("cookies = %v, want a and b", cookies)
+	}
+}
+
+// Test that headers named in Connection are dropped in both directions.
+func TestServeProxyDropsConnectionHeaders(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Saw-Hop", r.Header.Get("X-Hop"))
+		w.Header().Set("X-Saw-Kept", r.Header.Get("X-Kept"))
+		w.Header().Set("Connection", "X-Upstream-Hop")
+		w.Header().Set("X-Upstream-Hop", "1")
+	}))
+	defer upstream.Close()
+	withProxyConfig(t, appdata.ProxyConfig{BaseURL: upstream.URL})
+
+	res := serve(t, appdata.IncomingRequest{
+		Method: "GET",
+		URL:    "/hop",
+		Headers: map[string][]string{
+			"Connection": {"keep-alive, X-Hop"},
+			"X-Hop":      {"secret"},
+			"X-Kept":     {"yes"},
+		},
+	})
+
+	if got := res.Header().Get("X-Saw-Hop"); got != "" {
+		t.Errorf("upstream saw X-Hop = %q, want it dropped", got)
+	}
+	if got := res.Header().Get("X-Saw-Kept"); got != "yes" {
+		t.Errorf("upstream saw X-Kept = %q, want yes", got)
+	}
+	if got := res.Header().Get("X-Upstream-Hop"); got != "" {
+		t.Errorf("client got X-Upstream-Hop = %q, want it dropped", got)
+	}
+}
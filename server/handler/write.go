@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WriteResult sends res on w: a fault, a dribbled body or a plain response.
+// A dribbled body stops early when ctx is done.
+func WriteResult(ctx context.Context, w http.ResponseWriter, res Result) error {
+	if res.Fault != "" {
+		return WriteFault(w, res)
+	}
+
+	for k, vs := range res.Headers {
+		w.Header()[k] = vs
+	}
+	if res.DribbleChunks <= 0 || len(res.Body) == 0 {
+		w.WriteHeader(res.Status)
+		_, err := w.Write(res.Body)
+		return err
+	}
+
+	// Send the headers right away so the client sees a slow body, not a
+	// slow response.
+	rc := http.NewResponseController(w)
+	w.WriteHeader(res.Status)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+
+	chunks := splitChunks(res.Body, res.DribbleChunks)
+	interval := res.DribbleDuration / time.Duration(len(chunks))
+	for _, chunk := range chunks {
+		if !sleep(ctx, interval) {
+			return ctx.Err()
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitChunks cuts body into n nearly equal pieces (fewer if body is shorter).
+func splitChunks(body []byte, n int) [][]byte {
+	n = min(n, len(body))
+	out := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, body[i*len(body)/n:(i+1)*len(body)/n])
+	}
+	return out
+}
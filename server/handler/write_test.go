@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Srinu0342/mocknest/server/appdata"
+)
+
+func TestSplitChunks(t *testing.T) {
+	cases := []struct {
+		body string
+		n    int
+		want []string
+	}{
+		{"abcdef", 3, []string{"ab", "cd", "ef"}},
+		{"abcde", 2, []string{"ab", "cde"}},
+		{"ab", 5, []string{"a", "b"}},
+	}
+	for _, tc := range cases {
+		got := splitChunks([]byte(tc.body), tc.n)
+		if len(got) != len(tc.want) {
+			t.Fatalf("splitChunks(%q, %d) = %q, want %q", tc.body, tc.n, got, tc.want)
+		}
+		for i := range got {
+			if string(got[i]) != tc.want[i] {
+				t.Fatalf("splitChunks(%q, %d) = %q, want %q", tc.body, tc.n, got, tc.want)
+			}
+		}
+	}
+}
+
+// flushRecorder records what was written between flushes.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+	sent    int
+}
+
+func (r *flushRecorder) Flush() {
+	body := r.Body.String()
+	r.flushed = append(r.flushed, body[r.sent:])
+	r.sent = len(body)
+	r.ResponseRecorder.Flush()
+}
+
+// Test that a dribbled body is flushed in order, one chunk at a time, after
+// the headers are flushed on their own.
+func TestWriteResultDribble(t *testing.T) {
+	res := Result{
+		Status:          200,
+		Headers:         http.Header{"Content-Type": {"text/plain"}},
+		Body:            []byte("aaaabbbbccccdddd"),
+		DribbleChunks:   4,
+		DribbleDuration: 4 * time.Millisecond,
+	}
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := WriteResult(context.Background(), rec, res); err != nil {
+		t.Fatalf("WriteResult error = %v", err)
+	}
+
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("status %d headers %v", rec.Code, rec.Header())
+	}
+	if want := []string{"", "aaaa", "bbbb", "cccc", "dddd"}; !slices.Equal(rec.flushed, want) {
+		t.Fatalf("flushed %q, want %q", rec.flushed, want)
+	}
+}
+
+// Test that a client going away cuts a long delay short.
+func TestServeDelayCancelled(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:       "slow",
+		Request:  appdata.Request{Method: "GET", URLPattern: "/slow", URLMatch: "exact"},
+		Response: appdata.Response{FixedDelayMs: 10_000},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	if err := Serve(ctx, rec, appdata.IncomingRequest{Method: "GET", URL: "/slow"}); err != nil {
+		t.Fatalf("Serve error = %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("wrote %q after the client left", rec.Body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Serve returned after %v, want it to stop when the client leaves", elapsed)
+	}
+
+	history := appdata.GetCallHistory()
+	if last := history[len(history)-1]; last.MappingID != "slow" || last.Status != 0 {
+		t.Fatalf("recorded %q with status %d, want slow with 0", last.MappingID, last.Status)
+	}
+}
+
+// Test that a client going away during a dribble is recorded with status 0
+// and no response.
+func TestServeDribbleCancelled(t *testing.T) {
+	appdata.Global.Reset()
+	t.Cleanup(appdata.Global.Reset)
+
+	err := appdata.Global.Add(appdata.Mapping{
+		ID:      "dribble",
+		Request: appdata.Request{Method: "GET", URLPattern: "/dribble", URLMatch: "exact"},
+		Response: appdata.Response{
+			Status: 200,
+			Body:   "aaaabbbbccccdddd",
+			DelayDistribution: &appdata.DelayDistribution{
+				Type: appdata.DelayChunkedDribble, NumberOfChunks: 4, TotalDuration: 10_000,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	if err := Serve(ctx, rec, appdata.IncomingRequest{Method: "GET", URL: "/dribble"}); err == nil {
+		t.Fatal("Serve error = nil, want the cancelled context")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Serve returned after %v, want it to stop when the client leaves", elapsed)
+	}
+	if rec.Code != 200 {
+		t.Fatalf("status sent = %d, want the headers sent before the body", rec.Code)
+	}
+
+	history := appdata.GetCallHistory()
+	last := history[len(history)-1]
+	if last.MappingID != "dribble" || last.Status != 0 {
+		t.Fatalf("recorded %q with status %d, want dribble with 0", last.MappingID, last.Status)
+	}
+	if last.ResponseBody != nil {
+		t.Fatalf("recorded response body %v, want none", last.ResponseBody)
+	}
+}
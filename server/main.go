@@ -1,38 +1,126 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Srinu0342/mocknest/server/admin"
 	"github.com/Srinu0342/mocknest/server/appdata"
 	"github.com/Srinu0342/mocknest/server/generator"
 	"github.com/Srinu0342/mocknest/server/handler"
 )
 
 func main() {
-	generator.GenerateMappings()
-
-	// Admin endpoints
-	http.HandleFunc("/__admin/mocks", func(w http.ResponseWriter, r *http.Request) {
-		mocks := appdata.GetAllMappings()
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(mocks); err != nil {
-			http.Error(w, "failed to encode mocks json", http.StatusInternalServerError)
-			return
+	if err := generator.GenerateMappings(); err != nil {
+		log.Fatalf("Failed to load mocks: %v", err)
+	}
+
+	// Hot reload of the mocks directory; MOCKS_POLL_INTERVAL=0 disables it.
+	pollInterval := 2 * time.Second
+	if v := os.Getenv("MOCKS_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid MOCKS_POLL_INTERVAL %q: %v", v, err)
 		}
-	})
+		pollInterval = d
+	}
+	stopWatch := func() {}
+	if pollInterval > 0 {
+		stopWatch = generator.Watch(pollInterval)
+	}
 
-	http.HandleFunc("/__admin/history", func(w http.ResponseWriter, r *http.Request) {
-		history := appdata.GetCallHistory()
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(history); err != nil {
-			http.Error(w, "failed to encode history json", http.StatusInternalServerError)
-			return
+	// Optional chaos profile; it can also be changed through /__admin/chaos.
+	if path := os.Getenv("MOCKS_CHAOS_FILE"); path != "" {
+		if err := appdata.LoadChaosFile(path); err != nil {
+			log.Fatalf("invalid MOCKS_CHAOS_FILE: %v", err)
 		}
-	})
+	}
+
+	// Optional fallback upstream for requests no mapping matches.
+	if base := os.Getenv("MOCKS_PROXY_URL"); base != "" {
+		cfg := appdata.ProxyConfig{BaseURL: base}
+		if v := os.Getenv("MOCKS_PROXY_TIMEOUT"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				log.Fatalf("invalid MOCKS_PROXY_TIMEOUT %q: %v", v, err)
+			}
+			cfg.TimeoutMs = int(d / time.Millisecond)
+		}
+		if err := appdata.SetProxyConfig(cfg); err != nil {
+			log.Fatalf("invalid MOCKS_PROXY_URL: %v", err)
+		}
+	}
+
+	// Record mode: snapshot proxied exchanges into mocks/recorded/.
+	if v := os.Getenv("MOCKS_RECORD"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("invalid MOCKS_RECORD %q: %v", v, err)
+		}
+		cfg := generator.RecordConfig{Enabled: enabled}
+		if fields := os.Getenv("MOCKS_RECORD_BODY_FIELDS"); fields != "" {
+			cfg.BodyFields = strings.Split(fields, ",")
+		}
+		if err := generator.SetRecordConfig(cfg); err != nil {
+			log.Fatalf("invalid MOCKS_RECORD_BODY_FIELDS: %v", err)
+		}
+	}
+
+	// Opt-in near-miss diagnostics in the 404 body.
+	if v := os.Getenv("MOCKS_NEAR_MISSES_IN_404"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid MOCKS_NEAR_MISSES_IN_404 %q", v)
+		}
+		handler.NearMissesIn404 = n
+	}
+
+	// Call history limits; they can also be changed through /__admin/history/config.
+	historyConfig := appdata.DefaultHistoryConfig
+	if v := os.Getenv("MOCKS_HISTORY_MAX_ENTRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("invalid MOCKS_HISTORY_MAX_ENTRIES %q: %v", v, err)
+		}
+		historyConfig.MaxEntries = n
+	}
+	if v := os.Getenv("MOCKS_HISTORY_MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("invalid MOCKS_HISTORY_MAX_BODY_BYTES %q: %v", v, err)
+		}
+		historyConfig.MaxBodyBytes = n
+	}
+	if v := os.Getenv("MOCKS_HISTORY_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid MOCKS_HISTORY_TTL %q: %v", v, err)
+		}
+		historyConfig.TTLMs = int(d / time.Millisecond)
+	}
+	if v, ok := os.LookupEnv("MOCKS_HISTORY_REDACT_HEADERS"); ok {
+		historyConfig.RedactHeaders = nil
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				historyConfig.RedactHeaders = append(historyConfig.RedactHeaders, h)
+			}
+		}
+	}
+	if err := appdata.SetHistoryConfig(historyConfig); err != nil {
+		log.Fatalf("invalid history limits: %v", err)
+	}
+
+	admin.Register(http.DefaultServeMux)
 
 	// Catch-all mock handler
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -52,20 +140,18 @@ func main() {
 		incoming := appdata.IncomingRequest{
 			Method: r.Method,
 			// Use RequestURI so query string is visible for debugging; matching uses URL + Query.
-			URL:   r.URL.Path,
-			Query: r.URL.Query(),
-			Body:  body,
+			URL:         r.URL.Path,
+			Query:       r.URL.Query(),
+			Headers:     r.Header,
+			Body:        body,
+			RawBody:     bodyBytes,
+			EscapedPath: r.URL.EscapedPath(),
+			RawQuery:    r.URL.RawQuery,
+			RemoteAddr:  r.RemoteAddr,
 		}
 
-		status, headers, respBody := handler.Handler(incoming)
-
-		for k, v := range headers {
-			w.Header().Set(k, v)
-		}
-		w.WriteHeader(status)
-
-		if err := json.NewEncoder(w).Encode(respBody); err != nil {
-			http.Error(w, "failed to encode json", http.StatusInternalServerError)
+		if err := handler.Serve(r.Context(), w, incoming); err != nil {
+			log.Printf("failed to write response: %v", err)
 		}
 	})
 
@@ -74,6 +160,26 @@ func main() {
 		port = "8342"
 	}
 
+	// On SIGINT/SIGTERM stop the watcher and let in-flight requests finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{Addr: ":" + port}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		stopWatch()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
 	log.Println("listening on port:", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-stopped
+	log.Println("server stopped")
 }